@@ -1,8 +1,13 @@
 package config
 
 import (
+	"encoding/base64"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -18,3 +23,236 @@ func GetResendAPIKey() string {
 	apiKey := os.Getenv("RESEND_API_KEY")
 	return apiKey
 }
+
+// GetJobReportHideThreshold returns how many distinct users must report a job
+// before it is hidden automatically pending admin review.
+func GetJobReportHideThreshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("JOB_REPORT_HIDE_THRESHOLD"))
+	if err != nil || threshold < 1 {
+		return 5
+	}
+	return threshold
+}
+
+// GetEventTimezone returns the zone used when event times are shown in emails.
+// Defaults to Indian Standard Time.
+func GetEventTimezone() *time.Location {
+	name := os.Getenv("EVENT_TIMEZONE")
+	if name == "" {
+		name = "Asia/Kolkata"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.FixedZone("IST", 5*60*60+30*60)
+	}
+	return loc
+}
+
+func positiveIntEnv(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 1 {
+		return fallback
+	}
+	return value
+}
+
+// GetLoginFreeAttempts returns how many failed logins an account may have
+// before each further attempt is delayed with exponential backoff.
+func GetLoginFreeAttempts() int {
+	return positiveIntEnv("LOGIN_FREE_ATTEMPTS", 3)
+}
+
+// GetLoginIPFreeAttempts is GetLoginFreeAttempts for a client IP. It is higher
+// because campus networks put many users behind one address.
+func GetLoginIPFreeAttempts() int {
+	return positiveIntEnv("LOGIN_IP_FREE_ATTEMPTS", 20)
+}
+
+// GetLoginLockoutThreshold returns how many failed logins lock an account
+// until it is unlocked by email or the lockout expires.
+func GetLoginLockoutThreshold() int {
+	return positiveIntEnv("LOGIN_LOCKOUT_THRESHOLD", 10)
+}
+
+// GetLoginLockoutDuration returns how long a locked account stays locked.
+func GetLoginLockoutDuration() time.Duration {
+	return time.Duration(positiveIntEnv("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+// GetOTPMaxAttempts returns how many wrong guesses invalidate an email
+// verification code.
+func GetOTPMaxAttempts() int {
+	return positiveIntEnv("OTP_MAX_ATTEMPTS", 5)
+}
+
+// RateLimitEnabled reports whether write endpoints are rate limited. Set
+// RATE_LIMIT_ENABLED=false to turn limiting off, e.g. for load tests.
+func RateLimitEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv("RATE_LIMIT_ENABLED"))
+	return err != nil || enabled
+}
+
+// GetRateLimit reads a limit written as "<count>/<period>", e.g. "5/1m". It
+// reports false when the variable is unset or malformed.
+func GetRateLimit(name string) (int, time.Duration, bool) {
+	value := os.Getenv(name)
+	countPart, periodPart, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return 0, 0, false
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(countPart))
+	if err != nil || count < 1 {
+		return 0, 0, false
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(periodPart))
+	if err != nil || period <= 0 {
+		return 0, 0, false
+	}
+	return count, period, true
+}
+
+// OIDCConfig describes the college identity provider used for single sign-on.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // This backend's /api/v1/auth/oidc/callback
+	Scopes       []string
+	// Email domains allowed to sign in, e.g. "college.edu". Empty allows any.
+	AllowedDomains []string
+}
+
+// GetOIDCConfig returns the single sign-on settings. SSO is off unless
+// OIDC_ISSUER_URL, OIDC_CLIENT_ID and OIDC_REDIRECT_URL are all set.
+func GetOIDCConfig() (OIDCConfig, bool) {
+	cfg := OIDCConfig{
+		IssuerURL:    strings.TrimSpace(os.Getenv("OIDC_ISSUER_URL")),
+		ClientID:     strings.TrimSpace(os.Getenv("OIDC_CLIENT_ID")),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  strings.TrimSpace(os.Getenv("OIDC_REDIRECT_URL")),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	for _, domain := range strings.Split(os.Getenv("OIDC_ALLOWED_DOMAINS"), ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			cfg.AllowedDomains = append(cfg.AllowedDomains, domain)
+		}
+	}
+	return cfg, cfg.IssuerURL != "" && cfg.ClientID != "" && cfg.RedirectURL != ""
+}
+
+// GetFrontendURL returns the web app's base URL, used for links in emails and
+// redirects after single sign-on.
+func GetFrontendURL() string {
+	url := strings.TrimSuffix(strings.TrimSpace(os.Getenv("FRONTEND_URL")), "/")
+	if url == "" {
+		return "http://localhost:5173"
+	}
+	return url
+}
+
+// GetSecretKey returns SECRET_KEY. Besides being the "default" token key, it
+// signs one-time values such as 2FA challenges and unlock links, so it must
+// stay set even after that token key is retired.
+func GetSecretKey() string {
+	return os.Getenv("SECRET_KEY")
+}
+
+// JWTKeySpec is one key in JWT_KEYS.
+type JWTKeySpec struct {
+	ID        string
+	Algorithm string // HS256, RS256 or EdDSA
+	// The secret for HS256, or the path to a PEM file for RS256 and EdDSA. A
+	// PEM public key can only verify tokens. HS256 secrets are base64 encoded
+	// in JWT_KEYS, so they can't contain the separators, and decoded here.
+	Material string
+}
+
+// Minimum length of an HS256 secret from JWT_KEYS: the size of the hash.
+const minHS256SecretBytes = 32
+
+// JWTKeyConfig describes the keys access and refresh tokens are signed with.
+type JWTKeyConfig struct {
+	Keys     []JWTKeySpec
+	ActiveID string   // The key new tokens are signed with
+	Retired  []string // Keys whose tokens are no longer accepted
+}
+
+// LegacyJWTKeyID is the ID of the HS256 key taken from SECRET_KEY. Tokens
+// issued before key IDs were introduced have no kid and are checked with it.
+const LegacyJWTKeyID = "default"
+
+// GetJWTKeyConfig returns the token signing keys. JWT_KEYS is a comma
+// separated list of kid:algorithm:material entries; SECRET_KEY is always
+// added as the "default" HS256 key until JWT_RETIRED_KEYS retires it. The
+// active key is JWT_ACTIVE_KEY, or the first JWT_KEYS entry when unset.
+// Malformed entries are an error rather than being skipped.
+func GetJWTKeyConfig() (JWTKeyConfig, error) {
+	cfg := JWTKeyConfig{ActiveID: strings.TrimSpace(os.Getenv("JWT_ACTIVE_KEY"))}
+	for i, entry := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || strings.TrimSpace(parts[0]) == "" || parts[2] == "" {
+			return cfg, fmt.Errorf("JWT_KEYS entry %d is not in kid:algorithm:material form", i+1)
+		}
+		spec := JWTKeySpec{
+			ID:        strings.TrimSpace(parts[0]),
+			Algorithm: strings.TrimSpace(parts[1]),
+			Material:  parts[2],
+		}
+		if spec.Algorithm == "HS256" {
+			secret, err := base64.StdEncoding.DecodeString(spec.Material)
+			if err != nil {
+				return cfg, fmt.Errorf("JWT_KEYS key %q: HS256 secret must be base64 encoded", spec.ID)
+			}
+			if len(secret) < minHS256SecretBytes {
+				return cfg, fmt.Errorf("JWT_KEYS key %q: HS256 secret must be at least %d bytes", spec.ID, minHS256SecretBytes)
+			}
+			spec.Material = string(secret)
+		}
+		cfg.Keys = append(cfg.Keys, spec)
+	}
+	if cfg.ActiveID == "" && len(cfg.Keys) > 0 {
+		cfg.ActiveID = cfg.Keys[0].ID
+	}
+	if secret := GetSecretKey(); secret != "" {
+		cfg.Keys = append(cfg.Keys, JWTKeySpec{ID: LegacyJWTKeyID, Algorithm: "HS256", Material: secret})
+	}
+	if cfg.ActiveID == "" {
+		cfg.ActiveID = LegacyJWTKeyID
+	}
+	for _, id := range strings.Split(os.Getenv("JWT_RETIRED_KEYS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			cfg.Retired = append(cfg.Retired, id)
+		}
+	}
+	return cfg, nil
+}
+
+// GetAccountDeletionGracePeriod returns how long a deleted account can still
+// be restored by logging in before it is erased.
+func GetAccountDeletionGracePeriod() time.Duration {
+	return time.Duration(positiveIntEnv("ACCOUNT_DELETION_GRACE_DAYS", 14)) * 24 * time.Hour
+}
+
+// GetProxyConfig returns the reverse proxies in front of the app, as IPs or
+// CIDR ranges from TRUSTED_PROXIES, and the header they put the client's
+// address in (PROXY_HEADER, X-Real-IP by default). The header is only read
+// on requests from a trusted proxy; with none configured the connection's
+// address is used.
+func GetProxyConfig() (header string, proxies []string) {
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	header = strings.TrimSpace(os.Getenv("PROXY_HEADER"))
+	if header == "" {
+		header = "X-Real-IP"
+	}
+	return header, proxies
+}
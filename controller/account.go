@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+	"gradspaceBK/services"
+	"gradspaceBK/util"
+)
+
+func AccountRoutes(base *fiber.Group) error {
+	me := base.Group("/me", middlewares.AuthMiddleware)
+	me.Get("/export",
+		middlewares.RateLimit("account_export", middlewares.Limit{Count: 3, Period: time.Hour}, middlewares.Limit{}),
+		ExportAccountData)
+	me.Delete("/",
+		middlewares.RateLimit("delete_account", middlewares.Limit{Count: 5, Period: 15 * time.Minute}, middlewares.Limit{}),
+		DeleteAccount)
+	me.Post("/email",
+		middlewares.RateLimit("email_change", middlewares.Limit{Count: 3, Period: 15 * time.Minute}, middlewares.Limit{Count: 10, Period: 15 * time.Minute}),
+		RequestEmailChange)
+	me.Post("/email/verify", ConfirmEmailChange)
+	me.Post("/reauth",
+		middlewares.RateLimit("reauth_code", middlewares.Limit{Count: 3, Period: 15 * time.Minute}, middlewares.Limit{Count: 10, Period: 15 * time.Minute}),
+		SendReauthCode)
+	return nil
+}
+
+// DeleteAccount schedules the current user's account for deletion after the
+// grace period and logs them out everywhere. Logging in again before then
+// cancels it; afterwards services.ProcessDueAccountDeletions erases the
+// account. The password (or an emailed code for accounts without one), and a
+// 2FA code when 2FA is on, must be confirmed.
+func DeleteAccount(c *fiber.Ctx) error {
+	var req struct {
+		ReauthRequest
+		TwoFactorCodeRequest
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if user.Role == "Admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Admin accounts can only be removed by another admin",
+		})
+	}
+
+	session := database.Session.Db
+	if ok, err := confirmIdentity(c, session, *user, req.ReauthRequest); !ok {
+		return err
+	}
+	if user.TwoFactorEnabled {
+		verified, err := verifySecondFactor(session, *user, req.TwoFactorCodeRequest)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to delete account",
+			})
+		}
+		if !verified {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid verification code",
+			})
+		}
+	}
+
+	dueAt := time.Now().Add(config.GetAccountDeletionGracePeriod())
+	var revoked []string
+	err = session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("deletion_due_at", dueAt).Error; err != nil {
+			return err
+		}
+		if err := clearReauthCodes(tx, user.ID); err != nil {
+			return err
+		}
+		revoked, err = database.RevokeUserSessions(tx, user.ID, "")
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete account",
+		})
+	}
+	middlewares.ForgetSession(revoked...)
+	clearAuthCookies(c)
+
+	go func(user database.User) {
+		if err := sendAccountDeletionEmail(user, dueAt); err != nil {
+			log.Printf("Error sending account deletion email to %s: %v", user.ID, err)
+		}
+	}(*user)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":         true,
+		"message":         "Your account will be deleted. Log in again before then to keep it.",
+		"deletion_due_at": dueAt,
+	})
+}
+
+// cancelAccountDeletion keeps an account that was scheduled for deletion. It
+// fails if the account was erased in the meantime.
+func cancelAccountDeletion(user database.User) error {
+	if user.DeletionDueAt == nil {
+		return nil
+	}
+	result := database.Session.Db.Model(&database.User{}).
+		Where("id = ? AND anonymized_at IS NULL", user.ID).
+		Update("deletion_due_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("account %s has been deleted", user.ID)
+	}
+	return nil
+}
+
+func sendAccountDeletionEmail(user database.User, dueAt time.Time) error {
+	date := dueAt.In(config.GetEventTimezone()).Format("2 January 2006")
+	loginLink := frontendLink("/login")
+	data := map[string]interface{}{
+		"Username":     displayName(user),
+		"DeletionDate": date,
+		"LoginLink":    loginLink,
+	}
+	html, err := util.RenderTemplate("templates/account_deletion_email.html", data)
+	if err != nil {
+		return err
+	}
+
+	subject := "Your GradSpace account will be deleted"
+	text := fmt.Sprintf("Your account will be permanently deleted on %s. To keep it, log in before then: %s\n\nIf you didn't ask for this, log in and reset your password right away.", date, loginLink)
+	return services.SendEmail(user.Email, subject, text, html)
+}
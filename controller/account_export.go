@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+)
+
+// Columns of the user row included in an export. Password hashes, TOTP
+// secrets and provider subjects are left out.
+var exportUserColumns = []string{
+	"id", "full_name", "user_name", "department", "batch", "role", "email",
+	"is_verified", "is_onboard", "registration_status", "two_factor_enabled",
+	"deletion_due_at", "created_at", "updated_at",
+}
+
+// collectAccountData gathers the rows that make up a user's export, keyed by
+// section.
+func collectAccountData(session *gorm.DB, userID string) (map[string]interface{}, error) {
+	sections := []struct {
+		name  string
+		query *gorm.DB
+	}{
+		{"profile", session.Model(&database.UserProfile{}).Where("user_id = ?", userID)},
+		{"social_links", session.Model(&database.SocialLinks{}).Where("user_id = ?", userID)},
+		{"experience", session.Model(&database.Experience{}).Where("user_id = ?", userID).Order("start_date")},
+		{"education", session.Model(&database.Education{}).Where("user_id = ?", userID).Order("start_date")},
+		{"posts", session.Model(&database.Post{}).Where("author_id = ?", userID).Order("created_at")},
+		{"comments", session.Model(&database.Comment{}).Where("author_id = ?", userID).Order("created_at")},
+		{"messages", session.Model(&database.Message{}).Where("sender_id = ? OR receiver_id = ?", userID, userID).Order("created_at")},
+		{"jobs", session.Model(&database.Job{}).Where("posted_by = ?", userID).Order("created_at")},
+		{"events", session.Model(&database.Event{}).Where("posted_by = ?", userID).Order("start_date_time")},
+		{"projects", session.Model(&database.Project{}).Where("posted_by = ?", userID).Order("created_at")},
+	}
+
+	var users []map[string]interface{}
+	if err := session.Model(&database.User{}).Select(exportUserColumns).Where("id = ?", userID).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	data := map[string]interface{}{
+		"exported_at": time.Now().UTC(),
+		"user":        exportRow(users[0]),
+	}
+	for _, section := range sections {
+		var rows []map[string]interface{}
+		if err := section.query.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			rows[i] = exportRow(rows[i])
+		}
+		data[section.name] = rows
+	}
+	return data, nil
+}
+
+// exportRow keeps jsonb columns as JSON rather than base64 encoded bytes.
+func exportRow(row map[string]interface{}) map[string]interface{} {
+	for column, value := range row {
+		if raw, ok := value.([]byte); ok {
+			if json.Valid(raw) {
+				row[column] = json.RawMessage(raw)
+			} else {
+				row[column] = string(raw)
+			}
+		}
+	}
+	return row
+}
+
+// ExportAccountData downloads everything stored about the current user. The
+// default is a ZIP with data.json and the user's uploaded files under files/;
+// ?format=json returns just the data, listing the files by path.
+func ExportAccountData(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	session := database.Session.Db
+
+	data, err := collectAccountData(session, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to export account data",
+		})
+	}
+	files, err := database.UserUploadPaths(session, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to export account data",
+		})
+	}
+	data["files"] = files
+
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to export account data",
+		})
+	}
+	filename := "gradspace-export-" + time.Now().UTC().Format("2006-01-02")
+
+	if c.Query("format") == "json" {
+		c.Attachment(filename + ".json")
+		return c.Send(encoded)
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	if err := addZipFile(zw, "data.json", bytes.NewReader(encoded)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to export account data",
+		})
+	}
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			// Rows can outlive their files; export what is still there
+			if !os.IsNotExist(err) {
+				log.Printf("Error exporting %s for %s: %v", path, userID, err)
+			}
+			continue
+		}
+		name := "files/" + strings.TrimPrefix(filepath.ToSlash(path), "uploads/")
+		err = addZipFile(zw, name, file)
+		file.Close()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to export account data",
+			})
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to export account data",
+		})
+	}
+
+	c.Attachment(filename + ".zip")
+	return c.Send(archive.Bytes())
+}
+
+func addZipFile(zw *zip.Writer, name string, content io.Reader) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
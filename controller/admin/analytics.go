@@ -2,6 +2,7 @@ package admin
 
 import (
 	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,6 +10,7 @@ import (
 
 func RegisterAnalyticsRoutes(base *fiber.Group) error {
 	analytics := base.Group("/admin/analytics")
+	analytics.Use(middlewares.AuthMiddleware, middlewares.RequireRoles("Admin"))
 
 	analytics.Get("/user-distribution", GetUserDistribution)
 	analytics.Get("/department-data", GetDepartmentData)
@@ -28,6 +30,7 @@ func GetUserDistribution(c *fiber.Ctx) error {
 
 	session.Model(&database.User{}).
 		Select("role, COUNT(*) as count").
+		Where("anonymized_at IS NULL").
 		Group("role").
 		Scan(&userCounts)
 
@@ -0,0 +1,261 @@
+package admin
+
+import (
+	"fmt"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ReportedJob struct {
+	JobID        string         `json:"job_id"`
+	Title        string         `json:"title"`
+	CompanyName  string         `json:"company_name"`
+	PostedBy     string         `json:"posted_by"`
+	IsOpen       bool           `json:"is_open"`
+	IsHidden     bool           `json:"is_hidden"`
+	TotalReports int64          `json:"total_reports"`
+	Reasons      map[string]int `json:"reasons"`
+	LastReported string         `json:"last_reported"`
+}
+
+type JobReportActionRequest struct {
+	Action string `json:"action"` // "dismiss" or "takedown"
+	Reason string `json:"reason"`
+}
+
+func AdminJobReportRoutes(base *fiber.Group) error {
+	reports := base.Group("/admin/job-reports")
+	reports.Use(middlewares.AuthMiddleware, middlewares.RequireRoles("Admin"))
+
+	reports.Get("/", GetReportedJobs)
+	reports.Post("/:jobId/action", HandleJobReportAction)
+
+	return nil
+}
+
+// GetReportedJobs lists jobs with pending reports, most reported first.
+func GetReportedJobs(c *fiber.Ctx) error {
+	var pagination struct {
+		Page  int `query:"page"`
+		Limit int `query:"limit"`
+	}
+	if err := c.QueryParser(&pagination); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid query parameters",
+		})
+	}
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	if pagination.Limit < 1 || pagination.Limit > 100 {
+		pagination.Limit = 10
+	}
+
+	session := database.Session.Db
+
+	var total int64
+	if err := session.Model(&database.JobReport{}).
+		Where("status = ?", database.JobReportStatusPending).
+		Distinct("job_id").
+		Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to count reported jobs",
+		})
+	}
+
+	var rows []struct {
+		JobID        string
+		TotalReports int64
+	}
+	if err := session.Model(&database.JobReport{}).
+		Select("job_id, COUNT(*) AS total_reports, MAX(created_at) AS last_reported").
+		Where("status = ?", database.JobReportStatusPending).
+		Group("job_id").
+		Order("total_reports DESC, last_reported DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch reported jobs",
+		})
+	}
+
+	jobIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		jobIDs = append(jobIDs, row.JobID)
+	}
+
+	reportedJobs := make([]ReportedJob, 0, len(rows))
+	if len(jobIDs) > 0 {
+		var jobs []database.Job
+		if err := session.Preload("Company").Where("id IN ?", jobIDs).Find(&jobs).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to fetch jobs",
+			})
+		}
+		jobMap := make(map[string]database.Job)
+		for _, job := range jobs {
+			jobMap[job.ID] = job
+		}
+
+		var reasonCounts []struct {
+			JobID        string
+			Reason       string
+			Count        int
+			LastReported string
+		}
+		if err := session.Model(&database.JobReport{}).
+			Select("job_id, reason, COUNT(*) AS count, MAX(created_at)::text AS last_reported").
+			Where("status = ? AND job_id IN ?", database.JobReportStatusPending, jobIDs).
+			Group("job_id, reason").
+			Scan(&reasonCounts).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to fetch report reasons",
+			})
+		}
+		reasonMap := make(map[string]map[string]int)
+		lastReported := make(map[string]string)
+		for _, rc := range reasonCounts {
+			if reasonMap[rc.JobID] == nil {
+				reasonMap[rc.JobID] = make(map[string]int)
+			}
+			reasonMap[rc.JobID][rc.Reason] = rc.Count
+			if rc.LastReported > lastReported[rc.JobID] {
+				lastReported[rc.JobID] = rc.LastReported
+			}
+		}
+
+		for _, row := range rows {
+			job := jobMap[row.JobID]
+			reportedJobs = append(reportedJobs, ReportedJob{
+				JobID:        row.JobID,
+				Title:        job.Title,
+				CompanyName:  job.Company.Name,
+				PostedBy:     job.PostedBy,
+				IsOpen:       job.IsOpen,
+				IsHidden:     job.IsHidden,
+				TotalReports: row.TotalReports,
+				Reasons:      reasonMap[row.JobID],
+				LastReported: lastReported[row.JobID],
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"jobs": reportedJobs,
+			"pagination": fiber.Map{
+				"total": total,
+				"page":  pagination.Page,
+				"limit": pagination.Limit,
+			},
+		},
+	})
+}
+
+// HandleJobReportAction resolves all pending reports on a job. "dismiss"
+// clears them and restores a job that was auto-hidden; "takedown" closes and
+// hides the job and notifies the poster.
+func HandleJobReportAction(c *fiber.Ctx) error {
+	adminID := middlewares.AuthUserID(c)
+	jobID := c.Params("jobId")
+
+	var req JobReportActionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	session := database.Session.Db
+	var job database.Job
+	if err := session.First(&job, "id = ?", jobID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Job not found",
+		})
+	}
+
+	var status database.JobReportStatus
+	switch req.Action {
+	case "dismiss":
+		status = database.JobReportStatusDismissed
+	case "takedown":
+		if req.Reason == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Reason is required for this action",
+			})
+		}
+		status = database.JobReportStatusActioned
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid action",
+		})
+	}
+
+	var resolved int64
+	err := session.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.JobReport{}).
+			Where("job_id = ? AND status = ?", jobID, database.JobReportStatusPending).
+			Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		resolved = result.RowsAffected
+
+		if status == database.JobReportStatusDismissed {
+			// A job is only hidden while it has pending reports, unless an
+			// admin took it down earlier.
+			var actioned int64
+			if err := tx.Model(&database.JobReport{}).
+				Where("job_id = ? AND status = ?", jobID, database.JobReportStatusActioned).
+				Count(&actioned).Error; err != nil {
+				return err
+			}
+			if actioned == 0 {
+				return tx.Model(&database.Job{}).Where("id = ?", jobID).Update("is_hidden", false).Error
+			}
+			return nil
+		}
+
+		if err := tx.Model(&database.Job{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+			"is_open":   false,
+			"is_hidden": true,
+		}).Error; err != nil {
+			return err
+		}
+		notification := database.Notification{
+			UserID:    job.PostedBy,
+			CreatorID: adminID,
+			Type:      database.NotificationTypeJobTakedown,
+			JobID:     &job.ID,
+			Message:   fmt.Sprintf("Your job \"%s\" was taken down by an administrator. Reason: %s", job.Title, req.Reason),
+		}
+		return tx.Create(&notification).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to perform action",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"message":  "Action performed successfully",
+		"resolved": resolved,
+	})
+}
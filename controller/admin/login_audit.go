@@ -0,0 +1,111 @@
+package admin
+
+import (
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LoginAuditEntry struct {
+	ID        string    `json:"id"`
+	UserID    *string   `json:"user_id"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func AdminLoginAuditRoutes(base *fiber.Group) error {
+	audit := base.Group("/admin/login-audit")
+	audit.Use(middlewares.AuthMiddleware, middlewares.RequireRoles("Admin"))
+
+	audit.Get("/", GetLoginAudit)
+
+	return nil
+}
+
+// GetLoginAudit lists failed login attempts, newest first.
+func GetLoginAudit(c *fiber.Ctx) error {
+	var filters struct {
+		Email  string `query:"email"`
+		IP     string `query:"ip"`
+		UserID string `query:"user_id"`
+		Reason string `query:"reason"`
+		Page   int    `query:"page"`
+		Limit  int    `query:"limit"`
+	}
+	if err := c.QueryParser(&filters); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid query parameters",
+		})
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.Limit < 1 || filters.Limit > 100 {
+		filters.Limit = 20
+	}
+
+	query := database.Session.Db.Model(&database.LoginAudit{})
+	if filters.Email != "" {
+		query = query.Where("email = ?", filters.Email)
+	}
+	if filters.IP != "" {
+		query = query.Where("ip_address = ?", filters.IP)
+	}
+	if filters.UserID != "" {
+		query = query.Where("user_id = ?", filters.UserID)
+	}
+	if filters.Reason != "" {
+		query = query.Where("reason = ?", filters.Reason)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to count login attempts",
+		})
+	}
+
+	var records []database.LoginAudit
+	if err := query.Order("created_at DESC").
+		Offset((filters.Page - 1) * filters.Limit).
+		Limit(filters.Limit).
+		Find(&records).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch login attempts",
+		})
+	}
+
+	entries := make([]LoginAuditEntry, 0, len(records))
+	for _, record := range records {
+		entries = append(entries, LoginAuditEntry{
+			ID:        record.ID,
+			UserID:    record.UserID,
+			Email:     record.Email,
+			IPAddress: record.IPAddress,
+			UserAgent: record.UserAgent,
+			Reason:    string(record.Reason),
+			CreatedAt: record.CreatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"attempts": entries,
+			"pagination": fiber.Map{
+				"total": total,
+				"page":  filters.Page,
+				"limit": filters.Limit,
+			},
+		},
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
 	"gradspaceBK/services"
 	"gradspaceBK/util"
 
@@ -29,6 +30,7 @@ type UserActionRequest struct {
 
 func AdminUserManagementRoutes(base *fiber.Group) error {
 	user := base.Group("/admin/user-management")
+	user.Use(middlewares.AuthMiddleware, middlewares.RequireRoles("Admin"))
 
 	user.Post("/add-users/", AddUsers)
 	user.Post("/promote-batch/", PromoteBatchToAlumni)
@@ -170,6 +172,7 @@ func GetUsers(c *fiber.Ctx) error {
 	var users []database.User
 	query := session.Model(&database.User{})
 	query = query.Where("role != ?", "Admin")
+	query = query.Where("anonymized_at IS NULL") // Erased accounts only remain for their messages
 	if filters.Search != "" {
 		searchTerm := strings.TrimSpace(filters.Search)
 		query = query.Where("full_name LIKE ? OR email LIKE ?", "%"+searchTerm+"%", "%"+searchTerm+"%")
@@ -295,6 +298,8 @@ func PerformUserAction(c *fiber.Ctx) error {
                 "message": "Failed to remove user",
             })
         }
+        // Their sessions went with the account; drop any cached ones too
+        middlewares.ForgetUserSessions(user.ID)
         
 		 // Prepare userName safely
 		 userName := "User"
@@ -1,17 +1,17 @@
 package controller
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
-	"os"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 
-	"github.com/google/uuid"
-
+	"gradspaceBK/config"
 	"gradspaceBK/database"
 	"gradspaceBK/middlewares"
 
@@ -23,19 +23,38 @@ func AuthRoutes(base *fiber.Group) error {
 	auth := base.Group("/auth")
 
 	auth.Post("/login/", Login)
+	auth.Post("/login/2fa", LoginTwoFactor)
+	auth.Get("/oidc/login", OIDCLogin)
+	auth.Get("/oidc/callback", OIDCCallback)
 	auth.Post("/signup/", SignUp)
 	auth.Get("/check-auth/", middlewares.AuthMiddleware, CheckAuth)
-	auth.Get("/send-verification-otp/", middlewares.AuthMiddleware, SendVerificationOTP)
+	auth.Get("/send-verification-otp/", middlewares.AuthMiddleware,
+		middlewares.RateLimit("verification_otp", middlewares.Limit{Count: 3, Period: 15 * time.Minute}, middlewares.Limit{Count: 10, Period: 15 * time.Minute}),
+		SendVerificationOTP)
 	auth.Post("/verify-email", middlewares.AuthMiddleware, VerifyEmail)
 	auth.Post("/logout/", Logout)
-	auth.Post("/forgot-password", ForgotPassword)
+	auth.Get("/sessions", middlewares.AuthMiddleware, GetSessions)
+	auth.Delete("/sessions", middlewares.AuthMiddleware, RevokeAllSessions)
+	auth.Delete("/sessions/:id", middlewares.AuthMiddleware, RevokeSession)
+	auth.Post("/forgot-password",
+		middlewares.RateLimit("forgot_password", middlewares.Limit{}, middlewares.Limit{Count: 5, Period: 15 * time.Minute}),
+		ForgotPassword)
 	auth.Post("/reset-password/:token", ResetPassword)
+	auth.Post("/unlock/:token", UnlockAccount)
+	auth.Get("/jwks.json", GetJWKS)
+
+	twoFactor := auth.Group("/2fa", middlewares.AuthMiddleware)
+	twoFactorLimit := middlewares.RateLimit("two_factor", middlewares.Limit{Count: 10, Period: 15 * time.Minute}, middlewares.Limit{})
+	twoFactor.Get("/", GetTwoFactorStatus)
+	twoFactor.Post("/setup", twoFactorLimit, SetupTwoFactor)
+	twoFactor.Post("/enable", twoFactorLimit, EnableTwoFactor)
+	twoFactor.Post("/disable", twoFactorLimit, DisableTwoFactor)
+	twoFactor.Post("/recovery-codes", twoFactorLimit, RegenerateRecoveryCodes)
 	return nil
 }
 
 func SendVerificationOTP(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	session := database.Session.Db
 
@@ -61,7 +80,7 @@ func SendVerificationOTP(c *fiber.Ctx) error {
 
 	var verification database.Verification
 
-	if err := session.Where("user_id = ?", userID).First(&verification).Error; err != nil {
+	if err := session.Where("user_id = ? AND reset_password_token = '' AND new_email = '' AND purpose = ''", userID).First(&verification).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			verification = database.Verification{
 				UserID:            userID,
@@ -81,6 +100,7 @@ func SendVerificationOTP(c *fiber.Ctx) error {
 	} else {
 		verification.VerificationToken = otp
 		verification.ExpiresAt = time.Now().Add(5 * time.Minute)
+		verification.Attempts = 0
 		if err := session.Save(&verification).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"message": "Failed to update OTP",
@@ -112,8 +132,7 @@ func SendVerificationOTP(c *fiber.Ctx) error {
 }
 
 func VerifyEmail(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	type VerifyEmailRequest struct {
 		Code string `json:"code"`
@@ -142,22 +161,14 @@ func VerifyEmail(c *fiber.Ctx) error {
 	}
 
 	var verification database.Verification
-	if err := session.First(&verification, "user_id = ?", userID).Error; err != nil {
+	if err := session.First(&verification, "user_id = ? AND reset_password_token = '' AND new_email = '' AND purpose = ''", userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "OTP not found or expired",
 		})
 	}
 
-	if time.Now().After(verification.ExpiresAt) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "OTP has expired",
-		})
-	}
-
-	if request.Code != verification.VerificationToken {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid OTP",
-		})
+	if ok, err := checkVerificationCode(c, session, verification, request.Code); !ok {
+		return err
 	}
 
 	if err := session.Transaction(func(tx *gorm.DB) error {
@@ -197,6 +208,51 @@ func VerifyEmail(c *fiber.Ctx) error {
 	})
 }
 
+// checkVerificationCode counts a guess against an emailed code and compares
+// it. When the code is expired, wrong or out of attempts it writes the error
+// response and returns false with the result of writing it.
+func checkVerificationCode(c *fiber.Ctx, session *gorm.DB, verification database.Verification, code string) (bool, error) {
+	if time.Now().After(verification.ExpiresAt) {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "OTP has expired",
+		})
+	}
+
+	// Claim a guess before comparing so parallel requests can't exceed the cap
+	maxAttempts := config.GetOTPMaxAttempts()
+	claimed := session.Model(&database.Verification{}).
+		Where("id = ? AND attempts < ?", verification.ID, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if claimed.Error != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to verify OTP",
+		})
+	}
+	if claimed.RowsAffected == 0 {
+		session.Delete(&verification)
+		return false, c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"message": "Too many incorrect attempts. Please request a new code.",
+		})
+	}
+
+	if subtle.ConstantTimeCompare([]byte(code), []byte(verification.VerificationToken)) != 1 {
+		remaining := maxAttempts - verification.Attempts - 1
+		if remaining <= 0 {
+			session.Delete(&verification)
+			return false, c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"message": "Too many incorrect attempts. Please request a new code.",
+			})
+		}
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message":            "Invalid OTP",
+			"attempts_remaining": remaining,
+		})
+	}
+	return true, nil
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the email belongs to an account.
 func ForgotPassword(c *fiber.Ctx) error {
 	type RequestBody struct {
 		Email string `json:"email"`
@@ -208,55 +264,70 @@ func ForgotPassword(c *fiber.Ctx) error {
 			"message": "Invalid request body",
 		})
 	}
+	sent := func() error {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "If an account exists for this email, a password reset link has been sent",
+		})
+	}
 
 	session := database.Session.Db
 	user := database.User{}
-	if session.Where("email = ?", body.Email).First(&user).RowsAffected == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "User not found",
-		})
+	if session.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(body.Email))).Limit(1).Find(&user).RowsAffected == 0 {
+		return sent()
 	}
 
-	resetToken := uuid.New().String()
-	resetExpire := time.Now().Add(5 * time.Minute)
+	resetToken, err := randomToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
 
-	session.Create(&database.Verification{
-		UserID:             user.ID,
-		ResetPasswordToken: resetToken,
-		ExpiresAt:          resetExpire,
+	// Only the newest link works; the database only ever sees the token's hash
+	err = session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND reset_password_token <> ''", user.ID).
+			Delete(&database.Verification{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&database.Verification{
+			UserID:             user.ID,
+			ResetPasswordToken: util.HashToken(resetToken),
+			ExpiresAt:          time.Now().Add(5 * time.Minute),
+		}).Error
 	})
-
-	// Generate the reset password link with the reset token
-	// un comment this code on production environment.
-	var resetPasswordLink string
-	if os.Getenv("SERVER") == "prod" {
-		resetPasswordLink = fmt.Sprintf("https://feature-user.gradspace-frontend.pages.dev/reset-Password/%s", resetToken)
-	} else {
-		resetPasswordLink = fmt.Sprintf("http://localhost:5173/reset-Password/%s", resetToken)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
 	}
+
+	// Sent in the background so the response time doesn't reveal the account
+	go func() {
+		if err := sendResetPasswordEmail(user, resetToken); err != nil {
+			log.Printf("Error sending reset password email to user %s: %v", user.ID, err)
+		}
+	}()
+	return sent()
+}
+
+func sendResetPasswordEmail(user database.User, resetToken string) error {
+	resetPasswordLink := frontendLink("/reset-Password/" + resetToken)
 	data := map[string]string{
 		"ResetPasswordLink": resetPasswordLink,
-		"Username":          *user.UserName,
+		"Username":          displayName(user),
 	}
 	html, err := util.RenderTemplate("templates/reset_password_email.html", data)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to render email template",
-		})
+		return err
 	}
 
 	subject := "Reset Your Password"
 	text := fmt.Sprintf("Please click the following link to reset your password: %s", resetPasswordLink)
-	if err := services.SendEmail(user.Email, subject, text, html); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to send reset password email",
-		})
-	}
-
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Reset password email sent",
-	})
+	return services.SendEmail(user.Email, subject, text, html)
 }
+
+// ResetPassword sets a new password with a token from ForgotPassword. All of
+// the user's sessions are ended, so a stolen login stops working too.
 func ResetPassword(c *fiber.Ctx) error {
 	type ResetPasswordRequest struct {
 		Password string `json:"password"`
@@ -273,7 +344,9 @@ func ResetPassword(c *fiber.Ctx) error {
 
 	session := database.Session.Db
 	var verification database.Verification
-	if err := session.Where("reset_password_token = ?", token).First(&verification).Error; err != nil {
+	if err := session.Preload("User").
+		Where("reset_password_token = ?", util.HashToken(token)).
+		First(&verification).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Invalid or expired token",
 		})
@@ -286,6 +359,18 @@ func ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
+	user := verification.User
+	emailName, _, _ := strings.Cut(user.Email, "@")
+	personal := []string{emailName, user.FullName}
+	if user.UserName != nil {
+		personal = append(personal, *user.UserName)
+	}
+	if reason := util.CheckPasswordStrength(request.Password, personal...); reason != "" {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"message": reason,
+		})
+	}
+
 	hashedPassword, err := util.HashPassword(request.Password)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -293,16 +378,28 @@ func ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := session.Model(&database.User{}).Where("id = ?", verification.UserID).Update("password", hashedPassword).Error; err != nil {
+	var revoked []string
+	err = session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.User{}).Where("id = ?", user.ID).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND reset_password_token <> ''", user.ID).
+			Delete(&database.Verification{}).Error; err != nil {
+			return err
+		}
+		revoked, err = database.RevokeUserSessions(tx, user.ID, "")
+		return err
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to update password",
 		})
 	}
+	middlewares.ForgetSession(revoked...)
 
-	if err := session.Delete(&verification).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to delete verification record",
-		})
+	// A new password lifts any lockout from guesses at the old one
+	if err := clearLoginThrottle(session, database.ThrottleScopeAccount, user.ID); err != nil {
+		log.Printf("Error clearing failed logins for user %s: %v", user.ID, err)
 	}
 	// TODO: Send email to user.Email informing them that their password has been reset
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -327,70 +424,122 @@ func Login(c *fiber.Ctx) error {
 
 	email := formated_data.Email
 	password := formated_data.Password
+	ip := c.IP()
+
+	// Attempts are claimed before the password is checked, so a burst of
+	// parallel requests can't all get past the throttle
+	ipClaim, wait, err := claimLoginAttempt(session, database.ThrottleScopeIP, ip, config.GetLoginIPFreeAttempts(), 0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	if wait > 0 {
+		auditLoginFailure(c, nil, email, database.LoginFailureThrottled)
+		return tooManyLoginAttempts(c, wait, "Too many failed login attempts. Please try again later.")
+	}
+	releaseIP := func() {
+		if err := releaseLoginAttempt(session, ipClaim); err != nil {
+			log.Printf("Error releasing login attempt for %s: %v", ip, err)
+		}
+	}
 
 	if result := session.Model(&database.User{}).Where("email = ?", email).First(&user); result.Error == nil {
-		if err := util.ComparePassword(password, user.Password); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"message": "Invalid Username or Password",
+		claim, wait, err := claimLoginAttempt(session, database.ThrottleScopeAccount, user.ID,
+			config.GetLoginFreeAttempts(), config.GetLoginLockoutThreshold())
+		if err != nil {
+			releaseIP()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Internal Server Error",
 			})
 		}
-
-		// Retrieve profile image from UserProfile table.
-		var profile database.UserProfile
-		profileResult := session.Model(&database.UserProfile{}).
-			Where("user_id = ?", user.ID).
-			First(&profile)
-		profileImage := ""
-		if profileResult.Error == nil {
-			profileImage = profile.ProfileImage
+		if wait > 0 {
+			releaseIP()
+			if claim.Locked {
+				auditLoginFailure(c, &user.ID, email, database.LoginFailureLocked)
+				return tooManyLoginAttempts(c, wait, "This account is temporarily locked. Check your email for a link to unlock it.")
+			}
+			auditLoginFailure(c, &user.ID, email, database.LoginFailureThrottled)
+			return tooManyLoginAttempts(c, wait, "Too many failed login attempts. Please try again later.")
 		}
 
-		if token, err := util.GenerateToken(user.ID); err == nil {
-			access_cookie := &fiber.Cookie{
-				Name:     "access_token",
-				Value:    token["access_token"],
-				HTTPOnly: true,
-				Secure:   false,
-				SameSite: "None",
+		if err := util.ComparePassword(password, user.Password); err != nil {
+			auditLoginFailure(c, &user.ID, email, database.LoginFailureBadPassword)
+			if claim.Locked {
+				if err := sendAccountUnlockEmail(user, *claim.BlockedUntil); err != nil {
+					log.Printf("Error sending unlock email to user %s: %v", user.ID, err)
+				}
 			}
-			refresh_cookie := &fiber.Cookie{
-				Name:     "refresh_token",
-				Value:    token["refresh_token"],
-				HTTPOnly: true,
-				Secure:   false,
-				SameSite: "None",
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid Username or Password",
+			})
+		}
+		releaseIP()
+		// With 2FA on, earlier failures keep counting until the second step
+		// succeeds
+		if user.TwoFactorEnabled {
+			if err := releaseLoginAttempt(session, claim); err != nil {
+				log.Printf("Error releasing login attempt for user %s: %v", user.ID, err)
 			}
-			c.Cookie(access_cookie)
-			c.Cookie(refresh_cookie)
 			return c.Status(fiber.StatusOK).JSON(fiber.Map{
-				"success": true,
-				"message": "Login Successful",
-				"user": map[string]interface{}{
-					"id":                  user.ID,
-					"username":            *user.UserName,
-					"full_name":           user.FullName,
-					"role":                user.Role,
-					"department":          user.Department,
-					"batch":               user.Batch,
-					"email":               user.Email,
-					"is_verified":         user.IsVerified,
-					"is_onboard":          user.IsOnboard,
-					"registration_status": user.RegistrationStatus,
-					"created_at":          user.CreatedAt,
-					"updated_at":          user.UpdatedAt,
-					"profile_image":       profileImage,
-				},
+				"success":             true,
+				"message":             "Enter the code from your authenticator app",
+				"two_factor_required": true,
+				"challenge":           newTwoFactorChallenge(user.ID),
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Internal Server Error",
-		})
+		if err := clearLoginThrottle(session, database.ThrottleScopeAccount, user.ID); err != nil {
+			log.Printf("Error clearing failed logins for user %s: %v", user.ID, err)
+		}
+		return completeLogin(c, user)
 	}
+	auditLoginFailure(c, nil, email, database.LoginFailureUnknownEmail)
 	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 		"message": "Invalid Username or Password",
 	})
 }
 
+// completeLogin issues the session cookies and returns the user, once every
+// login step has passed.
+func completeLogin(c *fiber.Ctx, user database.User) error {
+	// Retrieve profile image from UserProfile table.
+	var profile database.UserProfile
+	profileResult := database.Session.Db.Model(&database.UserProfile{}).
+		Where("user_id = ?", user.ID).
+		First(&profile)
+	profileImage := ""
+	if profileResult.Error == nil {
+		profileImage = profile.ProfileImage
+	}
+
+	if err := setSessionCookies(c, user); err == nil {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"success": true,
+			"message": "Login Successful",
+			"user": map[string]interface{}{
+				"id":                  user.ID,
+				"username":            *user.UserName,
+				"full_name":           user.FullName,
+				"role":                user.Role,
+				"department":          user.Department,
+				"batch":               user.Batch,
+				"email":               user.Email,
+				"is_verified":         user.IsVerified,
+				"is_onboard":          user.IsOnboard,
+				"registration_status": user.RegistrationStatus,
+				"created_at":          user.CreatedAt,
+				"updated_at":          user.UpdatedAt,
+				"profile_image":       profileImage,
+			},
+			// Admins must enroll before they can use the admin endpoints
+			"two_factor_setup_required": user.Role == "Admin" && !user.TwoFactorEnabled,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": "Internal Server Error",
+	})
+}
+
 type SignUpData struct {
 	UserName string `json:"username"`
 	Email    string `json:"email"`
@@ -419,8 +568,8 @@ func SignUp(c *fiber.Ctx) error {
 	var existingUser database.User
 	// Check if email exists in the database
 	if err := session.Where("email = ?", email).First(&existingUser).Error; err == nil {
-		// Email exists
-		if existingUser.IsVerified {
+		// Email exists. Accounts with 2FA are never taken over by a new sign up.
+		if existingUser.IsVerified || existingUser.TwoFactorEnabled {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": "Email already exists",
 			})
@@ -441,9 +590,15 @@ func SignUp(c *fiber.Ctx) error {
 					"message": "Failed to update user",
 				})
 			}
+			// Whoever signed up with this email before loses access
+			if err := revokeSessions(existingUser.ID, ""); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"message": "Failed to update user",
+				})
+			}
 
 			// Generate tokens and proceed
-			tokens, err := util.GenerateToken(existingUser.ID)
+			tokens, err := startSession(c, existingUser)
 			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"message": "Failed to generate tokens",
@@ -509,7 +664,7 @@ func SignUp(c *fiber.Ctx) error {
 	}
 
 	// Generate tokens and respond
-	tokens, err := util.GenerateToken(newUser.ID)
+	tokens, err := startSession(c, newUser)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to generate tokens",
@@ -544,14 +699,15 @@ func SignUp(c *fiber.Ctx) error {
 }
 
 func CheckAuth(c *fiber.Ctx) error {
-	user_data := c.Locals("user_data").(jwt.MapClaims)
 	session := database.Session.Db
 
 	// Retrieve the user details.
-	user := database.User{}
-	session.Model(&database.User{}).
-		Where("id = ?", user_data["user_id"].(string)).
-		First(&user)
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Unauthorized",
+		})
+	}
 
 	// Retrieve the user's profile image.
 	var profile database.UserProfile
@@ -584,25 +740,17 @@ func CheckAuth(c *fiber.Ctx) error {
 }
 
 func Logout(c *fiber.Ctx) error {
-	// Create cookies with empty values and expired dates to clear them
-	access_cookie := &fiber.Cookie{
-		Name:     "access_token",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HTTPOnly: true,
-		Secure:   false,
-		SameSite: "None",
-	}
-	refresh_cookie := &fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HTTPOnly: true,
-		Secure:   false,
-		SameSite: "None",
+	// End this device's session so its tokens stop working even if they leak
+	if claims, err := util.VerifyToken(c.Cookies("access_token")); err == nil {
+		if err := database.Session.Db.Model(&database.UserSession{}).
+			Where("id = ? AND revoked_at IS NULL", claims.SessionID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			log.Printf("Error revoking session %s: %v", claims.SessionID, err)
+		}
+		middlewares.ForgetSession(claims.SessionID)
 	}
-	c.Cookie(access_cookie)
-	c.Cookie(refresh_cookie)
+
+	clearAuthCookies(c)
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Logout successful",
@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+	"gradspaceBK/services"
+	"gradspaceBK/util"
+)
+
+var errEmailTaken = errors.New("email address is already in use")
+
+// emailInUse reports whether an address belongs to a user or a pending
+// registration request. Registration requests are matched to users by email,
+// so both must stay unique.
+func emailInUse(tx *gorm.DB, email, exceptUserID string) (bool, error) {
+	var users, requests int64
+	if err := tx.Model(&database.User{}).
+		Where("LOWER(email) = ? AND id <> ?", email, exceptUserID).
+		Count(&users).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&database.RegisterRequest{}).
+		Where("LOWER(email) = ?", email).
+		Count(&requests).Error; err != nil {
+		return false, err
+	}
+	return users+requests > 0, nil
+}
+
+// RequestEmailChange starts changing the current user's email address. A code
+// is sent to the new address and the old one is told about the request. The
+// response is the same whether or not the new address is already registered,
+// in which case no code is sent. The password, or an emailed code for
+// accounts without one, must be confirmed.
+func RequestEmailChange(c *fiber.Ctx) error {
+	var req struct {
+		NewEmail string `json:"new_email"`
+		ReauthRequest
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	newEmail := strings.ToLower(strings.TrimSpace(req.NewEmail))
+	if address, err := mail.ParseAddress(newEmail); err != nil || address.Address != newEmail {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"message": "Enter a valid email address",
+		})
+	}
+
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if newEmail == strings.ToLower(user.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "This is already your email address",
+		})
+	}
+
+	session := database.Session.Db
+	if ok, err := confirmIdentity(c, session, *user, req.ReauthRequest); !ok {
+		return err
+	}
+	taken, err := emailInUse(session, newEmail, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to start email change",
+		})
+	}
+	if err := clearReauthCodes(session, user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to start email change",
+		})
+	}
+
+	var otp string
+	if !taken {
+		if otp, err = util.GenerateOtp(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to generate OTP",
+			})
+		}
+		// Only the newest request can be confirmed
+		err = session.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ? AND new_email <> ''", user.ID).
+				Delete(&database.Verification{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&database.Verification{
+				UserID:            user.ID,
+				VerificationToken: otp,
+				NewEmail:          newEmail,
+				ExpiresAt:         time.Now().Add(5 * time.Minute),
+			}).Error
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to start email change",
+			})
+		}
+	}
+
+	// Sent in the background so the response time doesn't reveal whether the
+	// address is registered
+	go func(user database.User) {
+		if otp != "" {
+			if err := sendEmailChangeCode(newEmail, otp); err != nil {
+				log.Printf("Error sending email change code for user %s: %v", user.ID, err)
+			}
+		}
+		if err := sendEmailChangeNotice(user, newEmail, false); err != nil {
+			log.Printf("Error sending email change notice to user %s: %v", user.ID, err)
+		}
+	}(*user)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "If this address can be used, a confirmation code has been sent to it",
+	})
+}
+
+// ConfirmEmailChange switches the current user to the new address once the
+// code sent there is entered. Other sessions are logged out.
+func ConfirmEmailChange(c *fiber.Ctx) error {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+
+	session := database.Session.Db
+	var verification database.Verification
+	if err := session.First(&verification, "user_id = ? AND new_email <> ''", user.ID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "No pending email change",
+		})
+	}
+	if ok, err := checkVerificationCode(c, session, verification, req.Code); !ok {
+		return err
+	}
+
+	oldEmail := user.Email
+	newEmail := verification.NewEmail
+	claims, _ := middlewares.AuthClaims(c)
+	var revoked []string
+	err = session.Transaction(func(tx *gorm.DB) error {
+		// The address may have been registered since the code was sent
+		taken, err := emailInUse(tx, newEmail, user.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errEmailTaken
+		}
+		if err := tx.Model(user).Update("email", newEmail).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&database.RegisterRequest{}).
+			Where("LOWER(email) = ?", strings.ToLower(oldEmail)).
+			Update("email", newEmail).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND new_email <> ''", user.ID).
+			Delete(&database.Verification{}).Error; err != nil {
+			return err
+		}
+		revoked, err = database.RevokeUserSessions(tx, user.ID, claims.SessionID)
+		return err
+	})
+	if errors.Is(err, errEmailTaken) {
+		session.Delete(&verification)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "This email address is already in use",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to change email address",
+		})
+	}
+	middlewares.ForgetSession(revoked...)
+
+	notified := *user
+	notified.Email = oldEmail
+	go func() {
+		if err := sendEmailChangeNotice(notified, newEmail, true); err != nil {
+			log.Printf("Error sending email change notice to user %s: %v", notified.ID, err)
+		}
+	}()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Email address updated",
+		"email":   newEmail,
+	})
+}
+
+func sendEmailChangeCode(to, otp string) error {
+	data := map[string]string{
+		"VerificationCode": otp,
+	}
+	html, err := util.RenderTemplate("templates/email_change_code_email.html", data)
+	if err != nil {
+		return err
+	}
+	subject := "Confirm your new GradSpace email address"
+	text := fmt.Sprintf("Your code to confirm this email address is: %s", otp)
+	return services.SendEmail(to, subject, text, html)
+}
+
+// sendEmailChangeNotice tells the user's current address about a requested
+// or completed change, with a way out if it wasn't them.
+func sendEmailChangeNotice(user database.User, newEmail string, completed bool) error {
+	resetLink := frontendLink("/forgot-password")
+	data := map[string]interface{}{
+		"Username":  displayName(user),
+		"NewEmail":  newEmail,
+		"Completed": completed,
+		"ResetLink": resetLink,
+	}
+	html, err := util.RenderTemplate("templates/email_change_notice_email.html", data)
+	if err != nil {
+		return err
+	}
+
+	subject := "Your GradSpace email address is being changed"
+	text := fmt.Sprintf("Someone asked to change your account's email address to %s. It only changes once the code sent there is entered.", newEmail)
+	if completed {
+		subject = "Your GradSpace email address was changed"
+		text = fmt.Sprintf("Your account's email address was changed to %s.", newEmail)
+	}
+	text += "\n\nIf this wasn't you, reset your password: " + resetLink
+	return services.SendEmail(user.Email, subject, text, html)
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"gradspaceBK/util"
+)
+
+// GetJWKS publishes the public keys tokens are signed with, so other services
+// can verify them without sharing a secret. Keys signing with HS256 are left
+// out; with only those configured the set is empty.
+func GetJWKS(c *fiber.Ctx) error {
+	ring, err := util.CurrentKeyring()
+	if err != nil {
+		log.Printf("Error loading signing keys: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Signing keys unavailable",
+		})
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"keys": ring.PublicKeys(),
+	})
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/services"
+	"gradspaceBK/util"
+)
+
+const (
+	accountUnlockPurpose = "account-unlock"
+	maxLoginBackoff      = 15 * time.Minute
+	// Failures older than this no longer count towards backoff or lockout
+	loginFailureWindow = 24 * time.Hour
+)
+
+// frontendLink returns an absolute link to a page of the web app.
+func frontendLink(path string) string {
+	return config.GetFrontendURL() + path
+}
+
+// displayName is how emails address the user.
+func displayName(user database.User) string {
+	if user.FullName != "" {
+		return user.FullName
+	}
+	if user.UserName != nil && *user.UserName != "" {
+		return *user.UserName
+	}
+	return "there"
+}
+
+// loginBackoff returns how long the next attempt must wait after the given
+// number of failures: nothing for the first free ones, then doubling from a
+// second up to maxLoginBackoff.
+func loginBackoff(failures, free int) time.Duration {
+	if failures < free {
+		return 0
+	}
+	shift := failures - free
+	if shift >= 10 {
+		return maxLoginBackoff
+	}
+	return min(time.Second<<shift, maxLoginBackoff)
+}
+
+// claimLoginAttempt reserves a login attempt for a subject before the
+// credentials are checked, so parallel requests can't all slip past the
+// throttle. The attempt is counted as a failure up front: it blocks the
+// subject for the backoff period, or locks it once lockAt failures are
+// reached (lockAt 0 never locks). A successful attempt undoes this with
+// releaseLoginAttempt or clearLoginThrottle.
+//
+// When the subject is still blocked nothing is counted, and the wait is
+// returned along with whether it comes from a lockout.
+func claimLoginAttempt(session *gorm.DB, scope database.ThrottleScope, subject string, free, lockAt int) (database.LoginThrottle, time.Duration, error) {
+	var throttle database.LoginThrottle
+	var wait time.Duration
+	err := session.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&database.LoginThrottle{
+			Scope:        scope,
+			Subject:      subject,
+			LastFailedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND subject = ?", scope, subject).
+			First(&throttle).Error; err != nil {
+			return err
+		}
+		if throttle.BlockedUntil != nil && throttle.BlockedUntil.After(now) {
+			wait = throttle.BlockedUntil.Sub(now)
+			return nil
+		}
+
+		if now.Sub(throttle.LastFailedAt) > loginFailureWindow {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+		throttle.LastFailedAt = now
+		throttle.Locked = lockAt > 0 && throttle.Failures >= lockAt
+
+		var until time.Time
+		if throttle.Locked {
+			until = now.Add(config.GetLoginLockoutDuration())
+		} else if delay := loginBackoff(throttle.Failures, free); delay > 0 {
+			until = now.Add(delay)
+		}
+		if until.IsZero() {
+			throttle.BlockedUntil = nil
+		} else {
+			throttle.BlockedUntil = &until
+		}
+		return tx.Save(&throttle).Error
+	})
+	return throttle, wait, err
+}
+
+// releaseLoginAttempt gives back an attempt claimed with claimLoginAttempt
+// that turned out not to be a failure. A block it set is lifted unless a
+// later failure has replaced it.
+func releaseLoginAttempt(session *gorm.DB, claimed database.LoginThrottle) error {
+	return session.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&database.LoginThrottle{}).
+			Where("scope = ? AND subject = ?", claimed.Scope, claimed.Subject).
+			Session(&gorm.Session{})
+		if err := query.Update("failures", gorm.Expr("GREATEST(failures - 1, 0)")).Error; err != nil {
+			return err
+		}
+		if claimed.BlockedUntil == nil {
+			return nil
+		}
+		return query.Where("blocked_until = ?", *claimed.BlockedUntil).
+			Updates(map[string]interface{}{"blocked_until": nil, "locked": false}).Error
+	})
+}
+
+func clearLoginThrottle(session *gorm.DB, scope database.ThrottleScope, subject string) error {
+	return session.Where("scope = ? AND subject = ?", scope, subject).Delete(&database.LoginThrottle{}).Error
+}
+
+// auditLoginFailure records a failed login. Failures to write the record are
+// logged rather than surfaced to the caller.
+func auditLoginFailure(c *fiber.Ctx, userID *string, email string, reason database.LoginFailureReason) {
+	audit := database.LoginAudit{
+		UserID:    userID,
+		Email:     truncate(email, 255),
+		IPAddress: c.IP(),
+		UserAgent: truncate(c.Get(fiber.HeaderUserAgent), 255),
+		Reason:    reason,
+	}
+	if err := database.Session.Db.Create(&audit).Error; err != nil {
+		log.Printf("Error recording failed login for %s: %v", audit.IPAddress, err)
+	}
+}
+
+func truncate(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	return strings.ToValidUTF8(value[:limit], "")
+}
+
+func tooManyLoginAttempts(c *fiber.Ctx, wait time.Duration, message string) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"message":     message,
+		"retry_after": seconds,
+	})
+}
+
+// sendAccountUnlockEmail tells the user their account was locked and links to
+// UnlockAccount. The token is tied to this lockout, so it stops working once
+// the account is unlocked or locked again.
+func sendAccountUnlockEmail(user database.User, lockedUntil time.Time) error {
+	token := util.SignValue(accountUnlockPurpose, fmt.Sprintf("%s|%d", user.ID, lockedUntil.Unix()))
+	unlockLink := frontendLink("/unlock-account/" + token)
+
+	minutes := int(config.GetLoginLockoutDuration().Minutes())
+	data := map[string]interface{}{
+		"Username":      displayName(user),
+		"UnlockLink":    unlockLink,
+		"LockedMinutes": minutes,
+	}
+	html, err := util.RenderTemplate("templates/account_locked_email.html", data)
+	if err != nil {
+		return err
+	}
+
+	subject := "Your GradSpace account has been locked"
+	text := fmt.Sprintf("We locked your account for %d minutes after several failed login attempts. If this was you, unlock it here: %s\n\nIf it wasn't you, reset your password.", minutes, unlockLink)
+	return services.SendEmail(user.Email, subject, text, html)
+}
+
+// UnlockAccount lifts a lockout using the link from the lockout email and
+// resets the account's failed login count.
+func UnlockAccount(c *fiber.Ctx) error {
+	invalid := func() error {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid or expired unlock link",
+		})
+	}
+
+	value, err := util.VerifySignedValue(accountUnlockPurpose, c.Params("token"))
+	if err != nil {
+		return invalid()
+	}
+	userID, lockedUntil, found := strings.Cut(value, "|")
+	if !found {
+		return invalid()
+	}
+
+	session := database.Session.Db
+	var throttle database.LoginThrottle
+	if err := session.Where("scope = ? AND subject = ? AND locked = ?", database.ThrottleScopeAccount, userID, true).
+		First(&throttle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalid()
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to unlock account",
+		})
+	}
+	if throttle.BlockedUntil == nil || strconv.FormatInt(throttle.BlockedUntil.Unix(), 10) != lockedUntil {
+		return invalid()
+	}
+
+	if err := session.Delete(&throttle).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to unlock account",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Account unlocked. You can log in again.",
+	})
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+	"gradspaceBK/services"
+	"gradspaceBK/util"
+)
+
+const (
+	oidcFlowCookie  = "oidc_flow"
+	oidcFlowPurpose = "oidc-flow"
+	oidcFlowTTL     = 10 * time.Minute
+)
+
+// oidcFlow is what the login step hands to the callback, in a signed cookie.
+type oidcFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	Expires  int64  `json:"expires"`
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// safeRedirectPath keeps post-login redirects inside the web app.
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+func setOIDCFlowCookie(c *fiber.Ctx, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    value,
+		Path:     "/api/v1/auth/oidc",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   false,
+		// The callback is a top-level redirect from the provider
+		SameSite: "Lax",
+	})
+}
+
+func readOIDCFlow(c *fiber.Ctx) (oidcFlow, bool) {
+	var flow oidcFlow
+	value, err := util.VerifySignedValue(oidcFlowPurpose, c.Cookies(oidcFlowCookie))
+	if err != nil || json.Unmarshal([]byte(value), &flow) != nil {
+		return flow, false
+	}
+	return flow, time.Now().Unix() <= flow.Expires
+}
+
+func emailDomainAllowed(email string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return false
+	}
+	for _, allowed := range domains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ssoFailure sends the browser back to the login page with a reason code.
+func ssoFailure(c *fiber.Ctx, reason string) error {
+	setOIDCFlowCookie(c, "", time.Now().Add(-1*time.Hour))
+	return c.Redirect(frontendLink("/login?sso_error="+reason), fiber.StatusFound)
+}
+
+// OIDCLogin starts single sign-on: it redirects to the identity provider
+// using the authorization code flow with PKCE.
+func OIDCLogin(c *fiber.Ctx) error {
+	cfg, enabled := config.GetOIDCConfig()
+	if !enabled {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Single sign-on is not configured",
+		})
+	}
+
+	provider, err := services.DiscoverOIDCProvider(c.Context(), cfg.IssuerURL)
+	if err != nil {
+		log.Printf("Error loading identity provider: %v", err)
+		return ssoFailure(c, "unavailable")
+	}
+
+	flow := oidcFlow{
+		Redirect: safeRedirectPath(c.Query("redirect")),
+		Expires:  time.Now().Add(oidcFlowTTL).Unix(),
+	}
+	for _, field := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *field, err = randomToken(); err != nil {
+			return ssoFailure(c, "failed")
+		}
+	}
+	encoded, err := json.Marshal(flow)
+	if err != nil {
+		return ssoFailure(c, "failed")
+	}
+	setOIDCFlowCookie(c, util.SignValue(oidcFlowPurpose, string(encoded)), time.Unix(flow.Expires, 0))
+
+	return c.Redirect(provider.AuthCodeURL(cfg.ClientID, cfg.RedirectURL, cfg.Scopes,
+		flow.State, flow.Nonce, flow.Verifier), fiber.StatusFound)
+}
+
+// OIDCCallback finishes single sign-on. The provider's email must belong to
+// a user already added by an admin; that user is marked verified, linked to
+// the provider account and logged in with the same cookies as Login.
+func OIDCCallback(c *fiber.Ctx) error {
+	cfg, enabled := config.GetOIDCConfig()
+	if !enabled {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Single sign-on is not configured",
+		})
+	}
+	if c.Query("error") != "" {
+		return ssoFailure(c, "cancelled")
+	}
+
+	flow, ok := readOIDCFlow(c)
+	if !ok || subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(flow.State)) != 1 {
+		return ssoFailure(c, "expired")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	provider, err := services.DiscoverOIDCProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		log.Printf("Error loading identity provider: %v", err)
+		return ssoFailure(c, "unavailable")
+	}
+	rawIDToken, err := provider.Exchange(ctx, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL, c.Query("code"), flow.Verifier)
+	if err != nil {
+		log.Printf("Error completing single sign-on: %v", err)
+		return ssoFailure(c, "failed")
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, cfg.ClientID, flow.Nonce)
+	if err != nil {
+		log.Printf("Error completing single sign-on: %v", err)
+		return ssoFailure(c, "failed")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" || (claims.EmailVerified != nil && !*claims.EmailVerified) {
+		return ssoFailure(c, "email_unverified")
+	}
+	if !emailDomainAllowed(email, cfg.AllowedDomains) {
+		return ssoFailure(c, "domain_not_allowed")
+	}
+
+	session := database.Session.Db
+	var user database.User
+	err = session.Where("oidc_subject = ?", claims.Subject).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = session.Where("LOWER(email) = ?", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ssoFailure(c, "not_provisioned")
+		}
+		// Another provider account was linked first
+		if err == nil && user.OIDCSubject != nil && *user.OIDCSubject != claims.Subject {
+			return ssoFailure(c, "account_mismatch")
+		}
+	}
+	if err != nil {
+		return ssoFailure(c, "failed")
+	}
+
+	// An unverified row that isn't linked yet may have been created through
+	// sign up by someone who doesn't own the address. Taking it over through
+	// the provider drops their password, 2FA and sessions so they can't get
+	// into the account once it is verified.
+	claimed := !user.IsVerified && user.OIDCSubject == nil
+	updates := map[string]interface{}{
+		"is_verified":  true,
+		"oidc_subject": claims.Subject,
+	}
+	if claimed {
+		updates["password"] = ""
+		updates["two_factor_enabled"] = false
+		updates["totp_secret"] = ""
+		updates["totp_last_step"] = 0
+	}
+	var revoked []string
+	err = session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if !claimed {
+			return nil
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&database.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		var err error
+		revoked, err = database.RevokeUserSessions(tx, user.ID, "")
+		return err
+	})
+	if err != nil {
+		return ssoFailure(c, "failed")
+	}
+	middlewares.ForgetSession(revoked...)
+	setOIDCFlowCookie(c, "", time.Now().Add(-1*time.Hour))
+
+	if user.TwoFactorEnabled {
+		challenge := url.QueryEscape(newTwoFactorChallenge(user.ID))
+		return c.Redirect(frontendLink("/login/2fa?challenge="+challenge), fiber.StatusFound)
+	}
+	if err := setSessionCookies(c, user); err != nil {
+		return ssoFailure(c, "failed")
+	}
+	return c.Redirect(frontendLink(flow.Redirect), fiber.StatusFound)
+}
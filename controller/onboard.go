@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 
 	"gradspaceBK/database"
 	"gradspaceBK/middlewares"
@@ -28,8 +27,7 @@ func OnboardRoutes(base *fiber.Group) error {
 
 
 func CompleteOnboarding(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -155,8 +153,7 @@ func CompleteOnboarding(c *fiber.Ctx) error {
 
 func CreateUserProfile(c *fiber.Ctx) error {
 	var userProfile database.UserProfile
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	ProfileImage, err := c.FormFile("profile_image")
 	if err == nil {
@@ -205,8 +202,7 @@ func CreateUserProfile(c *fiber.Ctx) error {
 }
 
 func CreateSocialLinks(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	type SocialLinkInput struct {
 		GithubURL          *string `json:"github_url,omitempty"`
@@ -248,8 +244,7 @@ func CreateSocialLinks(c *fiber.Ctx) error {
 }
 
 func CreateExperience(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	type ExperienceInput struct {
 		CompanyName  string     `json:"company_name"`
@@ -296,8 +291,7 @@ func CreateExperience(c *fiber.Ctx) error {
 }
 
 func CreateEducation(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	type EducationInput struct {
 		InstitutionName string     `json:"institution_name"`
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+	"gradspaceBK/services"
+	"gradspaceBK/util"
+)
+
+// ReauthRequest confirms a sensitive change. Accounts with a password give
+// the password; accounts that only sign in through SSO have none and give a
+// code from SendReauthCode instead.
+type ReauthRequest struct {
+	Password  string `json:"password"`
+	EmailCode string `json:"email_code"`
+}
+
+// SendReauthCode emails the current user a code that stands in for the
+// password on DELETE /me, POST /me/email and POST /auth/2fa/disable. It is only
+// for accounts without a password.
+func SendReauthCode(c *fiber.Ctx) error {
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if user.Password != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Confirm with your password instead",
+		})
+	}
+
+	otp, err := util.GenerateOtp()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to generate OTP",
+		})
+	}
+	// Only the newest code can be used
+	err = database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := clearReauthCodes(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(&database.Verification{
+			UserID:            user.ID,
+			VerificationToken: otp,
+			Purpose:           database.VerificationPurposeReauth,
+			ExpiresAt:         time.Now().Add(5 * time.Minute),
+		}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to store OTP",
+		})
+	}
+
+	if err := sendReauthCode(user.Email, otp); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to send OTP email",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "A confirmation code has been sent to your email address",
+	})
+}
+
+// confirmIdentity checks the password, or the emailed code for accounts
+// without one. When it fails it writes the error response, which the caller
+// returns. The code stays usable until the change is made and
+// clearReauthCodes removes it.
+func confirmIdentity(c *fiber.Ctx, session *gorm.DB, user database.User, req ReauthRequest) (bool, error) {
+	if user.Password != "" {
+		if err := util.ComparePassword(req.Password, user.Password); err != nil {
+			return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Incorrect password",
+			})
+		}
+		return true, nil
+	}
+
+	if req.EmailCode == "" {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message":             "Your account has no password. Confirm with a code sent to your email address.",
+			"email_code_required": true,
+		})
+	}
+	var verification database.Verification
+	if err := session.First(&verification, "user_id = ? AND purpose = ?",
+		user.ID, database.VerificationPurposeReauth).Error; err != nil {
+		return false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message":             "No confirmation code has been requested",
+			"email_code_required": true,
+		})
+	}
+	return checkVerificationCode(c, session, verification, req.EmailCode)
+}
+
+// clearReauthCodes uses up the user's confirmation codes once the change they
+// confirmed is made.
+func clearReauthCodes(tx *gorm.DB, userID string) error {
+	return tx.Where("user_id = ? AND purpose = ?", userID, database.VerificationPurposeReauth).
+		Delete(&database.Verification{}).Error
+}
+
+func sendReauthCode(to, otp string) error {
+	data := map[string]string{
+		"VerificationCode": otp,
+	}
+	html, err := util.RenderTemplate("templates/reauth_code_email.html", data)
+	if err != nil {
+		return err
+	}
+	subject := "Confirm a change to your GradSpace account"
+	text := fmt.Sprintf("Your code to confirm this change is: %s\n\nIf you did not request this, someone may be signed in to your account. Sign out of other sessions and contact support.", otp)
+	return services.SendEmail(to, subject, text, html)
+}
@@ -46,9 +46,12 @@ func SetupRouter(app *fiber.App) {
 	AuthRoutes(base_api.(*fiber.Group))
 	RegisterRoutes(base_api.(*fiber.Group))
 	OnboardRoutes(base_api.(*fiber.Group))
+	AccountRoutes(base_api.(*fiber.Group))
 	user.RegisterMessageRoutes(base_api.(*fiber.Group))
 	admin.AdminUserManagementRoutes(base_api.(*fiber.Group))
 	admin.RegisterAnalyticsRoutes(base_api.(*fiber.Group))
+	admin.AdminJobReportRoutes(base_api.(*fiber.Group))
+	admin.AdminLoginAuditRoutes(base_api.(*fiber.Group))
 	user.RegisterProfileRoutes(base_api.(*fiber.Group))
 	user.PostRoutes(base_api.(*fiber.Group))
 	user.NotificationRoutes(base_api.(*fiber.Group))
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+	"gradspaceBK/util"
+)
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// startSession records a new login from this request and issues its tokens.
+// Logging in during the deletion grace period keeps the account.
+func startSession(c *fiber.Ctx, user database.User) (map[string]string, error) {
+	if err := cancelAccountDeletion(user); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := database.UserSession{
+		UserID:     user.ID,
+		UserAgent:  truncate(c.Get(fiber.HeaderUserAgent), 255),
+		IPAddress:  c.IP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(util.SessionTTL),
+	}
+	if err := database.Session.Db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return util.GenerateToken(user.ID, user.Role, session.ID)
+}
+
+// setSessionCookies starts a session for the user and sets its tokens as
+// cookies.
+func setSessionCookies(c *fiber.Ctx, user database.User) error {
+	tokens, err := startSession(c, user)
+	if err != nil {
+		return err
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     "access_token",
+		Value:    tokens["access_token"],
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "None",
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    tokens["refresh_token"],
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "None",
+	})
+	return nil
+}
+
+func clearAuthCookies(c *fiber.Ctx) {
+	for _, name := range []string{"access_token", "refresh_token"} {
+		c.Cookie(&fiber.Cookie{
+			Name:     name,
+			Value:    "",
+			Expires:  time.Now().Add(-1 * time.Hour),
+			HTTPOnly: true,
+			Secure:   false,
+			SameSite: "None",
+		})
+	}
+}
+
+func revokeSessions(userID, keepID string) error {
+	ids, err := database.RevokeUserSessions(database.Session.Db, userID, keepID)
+	middlewares.ForgetSession(ids...)
+	return err
+}
+
+// GetSessions lists the devices the current user is logged in on, most
+// recently used first.
+func GetSessions(c *fiber.Ctx) error {
+	claims, _ := middlewares.AuthClaims(c)
+	userID, currentID := claims.UserID, claims.SessionID
+
+	var sessions []database.UserSession
+	if err := database.Session.Db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch sessions",
+		})
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.ID == currentID,
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":  true,
+		"sessions": response,
+	})
+}
+
+// RevokeSession signs out one of the current user's devices. Revoking the
+// current session also clears its cookies.
+func RevokeSession(c *fiber.Ctx) error {
+	claims, _ := middlewares.AuthClaims(c)
+	userID, currentID := claims.UserID, claims.SessionID
+	sessionID := c.Params("id")
+
+	var session database.UserSession
+	if err := database.Session.Db.
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Session not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to revoke session",
+		})
+	}
+	if err := database.Session.Db.Model(&session).Update("revoked_at", time.Now()).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to revoke session",
+		})
+	}
+	middlewares.ForgetSession(session.ID)
+
+	if session.ID == currentID {
+		clearAuthCookies(c)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Session revoked",
+	})
+}
+
+// RevokeAllSessions logs the current user out everywhere, this device
+// included.
+func RevokeAllSessions(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	if err := revokeSessions(userID, ""); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to revoke sessions",
+		})
+	}
+	clearAuthCookies(c)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Logged out of all devices",
+	})
+}
@@ -0,0 +1,418 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+	"gradspaceBK/util"
+)
+
+const (
+	twoFactorChallengePurpose = "login-2fa"
+	twoFactorChallengeTTL     = 5 * time.Minute
+	recoveryCodeCount         = 10
+	totpIssuer                = "GradSpace"
+)
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// newTwoFactorChallenge returns the token that carries a login from the
+// password step to the code step.
+func newTwoFactorChallenge(userID string) string {
+	expires := time.Now().Add(twoFactorChallengeTTL).Unix()
+	return util.SignValue(twoFactorChallengePurpose, fmt.Sprintf("%s|%d", userID, expires))
+}
+
+func readTwoFactorChallenge(token string) (string, bool) {
+	value, err := util.VerifySignedValue(twoFactorChallengePurpose, token)
+	if err != nil {
+		return "", false
+	}
+	userID, expires, found := strings.Cut(value, "|")
+	if !found {
+		return "", false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return "", false
+	}
+	return userID, true
+}
+
+// verifySecondFactor accepts a current TOTP code or an unused recovery code.
+// Both are single use: the code's time step and the recovery code are
+// consumed on success.
+func verifySecondFactor(session *gorm.DB, user database.User, req TwoFactorCodeRequest) (bool, error) {
+	if req.Code != "" {
+		step, ok := util.VerifyTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return false, nil
+		}
+		result := session.Model(&database.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		return result.RowsAffected == 1, result.Error
+	}
+	if req.RecoveryCode != "" {
+		result := session.Model(&database.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, util.HashRecoveryCode(req.RecoveryCode)).
+			Update("used_at", time.Now())
+		return result.RowsAffected == 1, result.Error
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes discards a user's recovery codes and returns a new set.
+// Only their hashes are kept, so this is the one time they can be shown.
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&database.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes, err := util.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]database.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		records = append(records, database.RecoveryCode{
+			UserID:   userID,
+			CodeHash: util.HashRecoveryCode(code),
+		})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// LoginTwoFactor is the second login step for accounts with 2FA. Wrong codes
+// count towards the account's backoff and lockout like wrong passwords.
+func LoginTwoFactor(c *fiber.Ctx) error {
+	var req struct {
+		Challenge string `json:"challenge"`
+		TwoFactorCodeRequest
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	userID, ok := readTwoFactorChallenge(req.Challenge)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Your login has expired. Please log in again.",
+		})
+	}
+
+	session := database.Session.Db
+	var user database.User
+	if err := session.First(&user, "id = ?", userID).Error; err != nil || !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Your login has expired. Please log in again.",
+		})
+	}
+
+	// Claimed before the code is checked, so parallel guesses are throttled
+	claim, wait, err := claimLoginAttempt(session, database.ThrottleScopeAccount, user.ID,
+		config.GetLoginFreeAttempts(), config.GetLoginLockoutThreshold())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	if wait > 0 {
+		if claim.Locked {
+			auditLoginFailure(c, &user.ID, user.Email, database.LoginFailureLocked)
+			return tooManyLoginAttempts(c, wait, "This account is temporarily locked. Check your email for a link to unlock it.")
+		}
+		auditLoginFailure(c, &user.ID, user.Email, database.LoginFailureThrottled)
+		return tooManyLoginAttempts(c, wait, "Too many failed login attempts. Please try again later.")
+	}
+
+	verified, err := verifySecondFactor(session, user, req.TwoFactorCodeRequest)
+	if err != nil {
+		if err := releaseLoginAttempt(session, claim); err != nil {
+			log.Printf("Error releasing login attempt for user %s: %v", user.ID, err)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	if !verified {
+		auditLoginFailure(c, &user.ID, user.Email, database.LoginFailureBadTOTP)
+		if claim.Locked {
+			if err := sendAccountUnlockEmail(user, *claim.BlockedUntil); err != nil {
+				log.Printf("Error sending unlock email to user %s: %v", user.ID, err)
+			}
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid verification code",
+		})
+	}
+
+	if err := clearLoginThrottle(session, database.ThrottleScopeAccount, user.ID); err != nil {
+		log.Printf("Error clearing failed logins for user %s: %v", user.ID, err)
+	}
+	return completeLogin(c, user)
+}
+
+// GetTwoFactorStatus reports whether 2FA is on for the current user.
+func GetTwoFactorStatus(c *fiber.Ctx) error {
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+
+	var remaining int64
+	if user.TwoFactorEnabled {
+		if err := database.Session.Db.Model(&database.RecoveryCode{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Count(&remaining).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to load two-factor status",
+			})
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":                  true,
+		"enabled":                  user.TwoFactorEnabled,
+		"required":                 user.Role == "Admin",
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTwoFactor creates a new TOTP secret for the current user. 2FA stays
+// off until EnableTwoFactor confirms the authenticator produces valid codes.
+func SetupTwoFactor(c *fiber.Ctx) error {
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to generate secret",
+		})
+	}
+	if err := database.Session.Db.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to save secret",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":          true,
+		"secret":           secret,
+		"provisioning_uri": util.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor turns 2FA on once the user enters a code from the
+// authenticator set up with SetupTwoFactor, and returns recovery codes.
+func EnableTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+	if user.TOTPSecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Set up two-factor authentication first",
+		})
+	}
+
+	step, ok := util.VerifyTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid verification code",
+		})
+	}
+
+	var codes []string
+	err = database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"two_factor_enabled": true,
+			"totp_last_step":     step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to enable two-factor authentication",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":        true,
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns 2FA off. It needs the password (or an emailed code
+// for accounts without one) and a 2FA code, and is refused for admins, who
+// must keep 2FA on.
+func DisableTwoFactor(c *fiber.Ctx) error {
+	var req struct {
+		ReauthRequest
+		TwoFactorCodeRequest
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+	if user.Role == "Admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Two-factor authentication is required for admin accounts",
+		})
+	}
+
+	session := database.Session.Db
+	if ok, err := confirmIdentity(c, session, *user, req.ReauthRequest); !ok {
+		return err
+	}
+	verified, err := verifySecondFactor(session, *user, req.TwoFactorCodeRequest)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+	if !verified {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid verification code",
+		})
+	}
+
+	err = session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"totp_secret":        "",
+			"totp_last_step":     0,
+		}).Error; err != nil {
+			return err
+		}
+		if err := clearReauthCodes(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&database.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes after
+// checking a TOTP code.
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+
+	var codes []string
+	err = database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		// A recovery code can't be used to mint new ones
+		verified, err := verifySecondFactor(tx, *user, TwoFactorCodeRequest{Code: req.Code})
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid verification code")
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"message": fiberErr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to regenerate recovery codes",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":        true,
+		"recovery_codes": codes,
+	})
+}
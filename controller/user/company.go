@@ -16,6 +16,9 @@ func CompanyRoutes(base *fiber.Group) {
 	company := base.Group("/companies")
 	company.Get("/", GetCompanies)
 	company.Get("/:id", GetCompany)
+	company.Get("/:id/profile", middlewares.AuthMiddleware, GetCompanyProfile)
+	company.Post("/:id/follow", middlewares.AuthMiddleware, ToggleCompanyFollow)
+	company.Post("/:id/merge", middlewares.AuthMiddleware, middlewares.RequireRoles("Admin"), MergeCompanies)
 	company.Post("/", middlewares.AuthMiddleware, AddCompany)
 	company.Put("/:id", middlewares.AuthMiddleware, UpdateCompany)
 	company.Delete("/:id", middlewares.AuthMiddleware, DeleteCompany)
@@ -38,6 +41,8 @@ func GetCompanies(c *fiber.Ctx) error {
 }
 
 func AddCompany(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
 	form, err := c.MultipartForm()
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -53,6 +58,26 @@ func AddCompany(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reject duplicates, and near duplicates unless the user confirms
+	existing, suggestions, err := findSimilarCompanies(database.Session.Db, name[0], "")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check existing companies",
+		})
+	}
+	if existing != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Company already exists",
+			"data":  existing,
+		})
+	}
+	if len(suggestions) > 0 && c.FormValue("confirm") != "true" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":       "Similar companies already exist. Resubmit with confirm=true to create it anyway",
+			"suggestions": suggestions,
+		})
+	}
+
 	// Handle logo upload
 	logoFile, err := c.FormFile("logo")
 	if err != nil {
@@ -87,8 +112,13 @@ func AddCompany(c *fiber.Ctx) error {
 
 	// Create company record
 	newCompany := database.Company{
-		Name:    name[0],
-		LogoURL: savePath,
+		Name:         name[0],
+		LogoURL:      savePath,
+		Description:  c.FormValue("description"),
+		Website:      c.FormValue("website"),
+		Industry:     c.FormValue("industry"),
+		Headquarters: c.FormValue("headquarters"),
+		CreatedBy:    &userID,
 	}
 
 	if err := database.Session.Db.Create(&newCompany).Error; err != nil {
@@ -106,6 +136,7 @@ func AddCompany(c *fiber.Ctx) error {
 }
 
 func UpdateCompany(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
 	companyID := c.Params("id")
 	var existingCompany database.Company
 
@@ -116,12 +147,42 @@ func UpdateCompany(c *fiber.Ctx) error {
 		})
 	}
 
+	if !canManageCompany(database.Session.Db, userID, existingCompany) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not authorized to modify this company",
+		})
+	}
+
 	form, err := c.MultipartForm()
 	if err == nil {
 		// Handle name update
-		if name, exists := form.Value["name"]; exists && len(name) > 0 {
+		if name, exists := form.Value["name"]; exists && len(name) > 0 && name[0] != "" {
+			duplicate, _, err := findSimilarCompanies(database.Session.Db, name[0], existingCompany.ID)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to check existing companies",
+				})
+			}
+			if duplicate != nil {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Another company with this name already exists",
+					"data":  duplicate,
+				})
+			}
 			existingCompany.Name = name[0]
 		}
+		if description, exists := form.Value["description"]; exists && len(description) > 0 {
+			existingCompany.Description = description[0]
+		}
+		if website, exists := form.Value["website"]; exists && len(website) > 0 {
+			existingCompany.Website = website[0]
+		}
+		if industry, exists := form.Value["industry"]; exists && len(industry) > 0 {
+			existingCompany.Industry = industry[0]
+		}
+		if headquarters, exists := form.Value["headquarters"]; exists && len(headquarters) > 0 {
+			existingCompany.Headquarters = headquarters[0]
+		}
 
 		// Handle logo update
 		if logoFile, err := c.FormFile("logo"); err == nil {
@@ -164,6 +225,7 @@ func UpdateCompany(c *fiber.Ctx) error {
 }
 
 func DeleteCompany(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
 	companyID := c.Params("id")
 	var company database.Company
 
@@ -175,9 +237,19 @@ func DeleteCompany(c *fiber.Ctx) error {
 		})
 	}
 
-	// Delete logo file if exists
-	if company.LogoURL != "" {
-		os.Remove(company.LogoURL)
+	if !canManageCompany(database.Session.Db, userID, company) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not authorized to delete this company",
+		})
+	}
+
+	// Jobs are never deleted along with their company
+	var jobCount int64
+	database.Session.Db.Model(&database.Job{}).Where("company_id = ?", company.ID).Count(&jobCount)
+	if jobCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Company has job listings. Merge it into another company instead",
+		})
 	}
 
 	// Delete company record
@@ -187,6 +259,11 @@ func DeleteCompany(c *fiber.Ctx) error {
 		})
 	}
 
+	// Delete logo file if exists
+	if company.LogoURL != "" {
+		os.Remove(company.LogoURL)
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Company deleted successfully",
@@ -0,0 +1,235 @@
+package user
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Normalized names at least this similar are offered as suggestions when a
+// new company is created.
+const companySimilarityThreshold = 0.8
+
+const maxCompanySuggestions = 5
+
+type CompanySuggestion struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	LogoURL    string  `json:"logo_url"`
+	Similarity float64 `json:"similarity"`
+}
+
+// findSimilarCompanies returns the company whose normalized name equals
+// name's, if any, and otherwise the closest fuzzy matches. excludeID skips the
+// company being renamed.
+func findSimilarCompanies(session *gorm.DB, name, excludeID string) (*database.Company, []CompanySuggestion, error) {
+	target := database.NormalizeCompanyName(name)
+
+	var companies []database.Company
+	query := session.Select("id", "name", "normalized_name", "logo_url")
+	if excludeID != "" {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Find(&companies).Error; err != nil {
+		return nil, nil, err
+	}
+
+	suggestions := make([]CompanySuggestion, 0)
+	for i, company := range companies {
+		normalized := database.NormalizeCompanyName(company.Name)
+		if company.NormalizedName != nil {
+			normalized = *company.NormalizedName
+		}
+		if normalized == target {
+			return &companies[i], nil, nil
+		}
+
+		similarity := nameSimilarity(target, normalized)
+		if similarity >= companySimilarityThreshold || sharesLeadingWord(target, normalized) {
+			suggestions = append(suggestions, CompanySuggestion{
+				ID:         company.ID,
+				Name:       company.Name,
+				LogoURL:    company.LogoURL,
+				Similarity: similarity,
+			})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Similarity > suggestions[j].Similarity
+	})
+	if len(suggestions) > maxCompanySuggestions {
+		suggestions = suggestions[:maxCompanySuggestions]
+	}
+	return nil, suggestions, nil
+}
+
+// sharesLeadingWord catches names like "google" and "google india" where one
+// is the other plus extra words.
+func sharesLeadingWord(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return strings.HasPrefix(a, b+" ") || strings.HasPrefix(b, a+" ")
+}
+
+// nameSimilarity is 1 minus the Levenshtein distance divided by the length of
+// the longer name.
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	longest := max(len(ra), len(rb))
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// canManageCompany reports whether the user created the company or is an
+// admin with two-factor authentication on.
+func canManageCompany(session *gorm.DB, userID string, company database.Company) bool {
+	if company.CreatedBy != nil && *company.CreatedBy == userID {
+		return true
+	}
+	var user database.User
+	if err := session.Select("id", "role", "two_factor_enabled").First(&user, "id = ?", userID).Error; err != nil {
+		return false
+	}
+	return middlewares.HasRole(&user, "Admin")
+}
+
+// MergeCompanies folds the companies in source_ids into the company in the
+// URL. Jobs, experiences and follows move to the surviving company and the
+// sources are deleted.
+func MergeCompanies(c *fiber.Ctx) error {
+	targetID := c.Params("id")
+
+	var req struct {
+		SourceIDs []string `json:"source_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil || len(req.SourceIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "source_ids is required",
+		})
+	}
+	// A company listed twice is only merged once
+	slices.Sort(req.SourceIDs)
+	req.SourceIDs = slices.Compact(req.SourceIDs)
+	for _, id := range req.SourceIDs {
+		if id == targetID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "A company cannot be merged into itself",
+			})
+		}
+	}
+
+	session := database.Session.Db
+
+	var target database.Company
+	if err := session.First(&target, "id = ?", targetID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	var sources []database.Company
+	if err := session.Where("id IN ?", req.SourceIDs).Find(&sources).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch companies",
+		})
+	}
+	if len(sources) != len(req.SourceIDs) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "One or more source companies not found",
+		})
+	}
+
+	sourceNames := make([]string, 0, len(sources))
+	for _, source := range sources {
+		sourceNames = append(sourceNames, database.NormalizeCompanyName(source.Name))
+	}
+
+	var jobsMoved, experiencesMoved, followsMoved int64
+	err := session.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.Job{}).
+			Where("company_id IN ?", req.SourceIDs).
+			Update("company_id", target.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		jobsMoved = result.RowsAffected
+
+		result = tx.Model(&database.Experience{}).
+			Where("company_id IN ? OR (company_id IS NULL AND normalized_company_name IN ?)", req.SourceIDs, sourceNames).
+			Updates(map[string]interface{}{
+				"company_id":   target.ID,
+				"company_name": target.Name,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		experiencesMoved = result.RowsAffected
+
+		// Users following both companies keep a single follow
+		if err := tx.Where("company_id IN ? AND user_id IN (?)", req.SourceIDs,
+			tx.Model(&database.CompanyFollow{}).Select("user_id").Where("company_id = ?", target.ID),
+		).Delete(&database.CompanyFollow{}).Error; err != nil {
+			return err
+		}
+		result = tx.Model(&database.CompanyFollow{}).
+			Where("company_id IN ?", req.SourceIDs).
+			Update("company_id", target.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		followsMoved = result.RowsAffected
+
+		return tx.Where("id IN ?", req.SourceIDs).Delete(&database.Company{}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to merge companies",
+		})
+	}
+
+	for _, source := range sources {
+		if source.LogoURL != "" && source.LogoURL != target.LogoURL {
+			os.Remove(source.LogoURL)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"company":           target,
+			"merged":            len(sources),
+			"jobs_moved":        jobsMoved,
+			"experiences_moved": experiencesMoved,
+			"follows_moved":     followsMoved,
+		},
+	})
+}
@@ -0,0 +1,234 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CompanyAlumnus struct {
+	UserID       string  `json:"user_id"`
+	FullName     string  `json:"full_name"`
+	UserName     string  `json:"username"`
+	ProfileImage string  `json:"profile_image"`
+	Role         string  `json:"role"`
+	Batch        int     `json:"batch"`
+	Department   string  `json:"department"`
+	Position     string  `json:"position"`
+	StartDate    string  `json:"start_date"`
+	EndDate      *string `json:"end_date"`
+}
+
+type CompanyJob struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Location  string `json:"location"`
+	JobType   string `json:"job_type"`
+	ApplyLink string `json:"apply_link"`
+	CreatedAt string `json:"created_at"`
+}
+
+// GetCompanyProfile returns the company page: details, open jobs and the
+// GradSpace members who list the company in their experience.
+func GetCompanyProfile(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	companyID := c.Params("id")
+	session := database.Session.Db
+
+	var company database.Company
+	if err := session.First(&company, "id = ?", companyID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	var jobs []database.Job
+	if err := session.
+		Where("company_id = ? AND is_open = ? AND is_hidden = ?", company.ID, true, false).
+		Order("created_at DESC").
+		Find(&jobs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch company jobs",
+		})
+	}
+	openJobs := make([]CompanyJob, 0, len(jobs))
+	for _, job := range jobs {
+		openJobs = append(openJobs, CompanyJob{
+			ID:        job.ID,
+			Title:     job.Title,
+			Location:  job.Location,
+			JobType:   job.JobType,
+			ApplyLink: job.ApplyLink,
+			CreatedAt: job.CreatedAt.Format("2006-01-02"),
+		})
+	}
+
+	current, past, err := getCompanyAlumni(session, company)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch alumni",
+		})
+	}
+
+	var followerCount int64
+	session.Model(&database.CompanyFollow{}).Where("company_id = ?", company.ID).Count(&followerCount)
+
+	var follow database.CompanyFollow
+	isFollowing := session.Where("user_id = ? AND company_id = ?", userID, company.ID).First(&follow).Error == nil
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id":             company.ID,
+			"name":           company.Name,
+			"logo_url":       company.LogoURL,
+			"description":    company.Description,
+			"website":        company.Website,
+			"industry":       company.Industry,
+			"headquarters":   company.Headquarters,
+			"open_jobs":      openJobs,
+			"follower_count": followerCount,
+			"is_following":   isFollowing,
+			"alumni": fiber.Map{
+				"current": current,
+				"past":    past,
+			},
+		},
+	})
+}
+
+// getCompanyAlumni matches experiences linked to the company, or unlinked
+// ones with the same name. Each user is listed once: as current if any of
+// their experiences there has no end date, otherwise as past with their most
+// recent position.
+func getCompanyAlumni(session *gorm.DB, company database.Company) ([]CompanyAlumnus, []CompanyAlumnus, error) {
+	var rows []struct {
+		UserID       string
+		FullName     string
+		UserName     *string
+		ProfileImage string
+		Role         string
+		Batch        int
+		Department   string
+		Position     string
+		StartDate    time.Time
+		EndDate      *time.Time
+	}
+	err := session.Table("experiences").
+		Select(`experiences.user_id, users.full_name, users.user_name, user_profiles.profile_image,
+			users.role, users.batch, users.department, experiences.position,
+			experiences.start_date, experiences.end_date`).
+		Joins("JOIN users ON users.id = experiences.user_id").
+		Joins("LEFT JOIN user_profiles ON user_profiles.user_id = users.id").
+		Where("experiences.company_id = ? OR (experiences.company_id IS NULL AND experiences.normalized_company_name = ?)",
+			company.ID, database.NormalizeCompanyName(company.Name)).
+		Order("experiences.end_date IS NOT NULL, experiences.start_date DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	current := make([]CompanyAlumnus, 0)
+	past := make([]CompanyAlumnus, 0)
+	for _, row := range rows {
+		// Rows are ordered current first, then newest first
+		if seen[row.UserID] {
+			continue
+		}
+		seen[row.UserID] = true
+
+		userName := ""
+		if row.UserName != nil {
+			userName = *row.UserName
+		}
+		alumnus := CompanyAlumnus{
+			UserID:       row.UserID,
+			FullName:     row.FullName,
+			UserName:     userName,
+			ProfileImage: row.ProfileImage,
+			Role:         row.Role,
+			Batch:        row.Batch,
+			Department:   row.Department,
+			Position:     row.Position,
+			StartDate:    row.StartDate.Format("2006-01-02"),
+		}
+		if row.EndDate == nil {
+			current = append(current, alumnus)
+			continue
+		}
+		endDate := row.EndDate.Format("2006-01-02")
+		alumnus.EndDate = &endDate
+		past = append(past, alumnus)
+	}
+	return current, past, nil
+}
+
+func ToggleCompanyFollow(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	companyID := c.Params("id")
+	session := database.Session.Db
+
+	var company database.Company
+	if err := session.First(&company, "id = ?", companyID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	var existing database.CompanyFollow
+	err := session.Where("user_id = ? AND company_id = ?", userID, companyID).First(&existing).Error
+	if err == nil {
+		if err := session.Delete(&existing).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to unfollow company",
+			})
+		}
+		return c.JSON(fiber.Map{"success": true, "action": "unfollowed"})
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	if err := session.Create(&database.CompanyFollow{UserID: userID, CompanyID: companyID}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to follow company",
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "action": "followed"})
+}
+
+// notifyCompanyFollowers creates a COMPANY_JOB notification for everyone
+// following the job's company, except the poster.
+func notifyCompanyFollowers(tx *gorm.DB, job database.Job, companyName string) error {
+	var followerIDs []string
+	if err := tx.Model(&database.CompanyFollow{}).
+		Where("company_id = ? AND user_id <> ?", job.CompanyID, job.PostedBy).
+		Pluck("user_id", &followerIDs).Error; err != nil {
+		return err
+	}
+	if len(followerIDs) == 0 {
+		return nil
+	}
+
+	notifications := make([]database.Notification, 0, len(followerIDs))
+	for _, followerID := range followerIDs {
+		jobID := job.ID
+		notifications = append(notifications, database.Notification{
+			UserID:    followerID,
+			CreatorID: job.PostedBy,
+			Type:      database.NotificationTypeCompanyJob,
+			JobID:     &jobID,
+			Message:   fmt.Sprintf("%s posted a new opening: %s", companyName, job.Title),
+		})
+	}
+	return tx.Create(&notifications).Error
+}
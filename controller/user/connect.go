@@ -193,7 +193,6 @@ import (
 	"gradspaceBK/middlewares"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
@@ -204,8 +203,7 @@ func ConnectRoutes(base *fiber.Group) {
 }
 
 func GetSuggestedConnectUsers(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	currentUserID := userData["user_id"].(string)
+	currentUserID := middlewares.AuthUserID(c)
 	session := database.Session.Db
 
 	var currentUserProfile database.UserProfile
@@ -270,8 +268,7 @@ type UserFilters struct {
 }
 
 func GetUsers(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	currentUserID := userData["user_id"].(string)
+	currentUserID := middlewares.AuthUserID(c)
 	session := database.Session.Db
 
 	var filters UserFilters
@@ -9,17 +9,33 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 func EventRoutes(base *fiber.Group) {
 	event := base.Group("/events")
 	event.Get("/", middlewares.AuthMiddleware, GetEvents)
 	event.Get("/saved", middlewares.AuthMiddleware, GetSavedEvents)
+	event.Get("/calendar-feed", middlewares.AuthMiddleware, GetCalendarFeedURL)
+	event.Post("/calendar-feed/reset", middlewares.AuthMiddleware, ResetCalendarFeedURL)
+	// Public: calendar apps authenticate with the token in the URL
+	event.Get("/feed/:token", GetCalendarFeed)
 	event.Post("/save", middlewares.AuthMiddleware, SaveEvent)
 	event.Patch("/:id/status", middlewares.AuthMiddleware, UpdateRegistrationStatus)
 	event.Get("/my-events", middlewares.AuthMiddleware, GetMyEvents)
+	event.Get("/pending", middlewares.AuthMiddleware, middlewares.RequireRoles("Faculty", "Admin"), GetPendingEvents)
+	event.Post("/:id/review", middlewares.AuthMiddleware, middlewares.RequireRoles("Faculty", "Admin"), ReviewEvent)
+	event.Patch("/:id", middlewares.AuthMiddleware, UpdateEvent)
 	event.Delete("/:id", middlewares.AuthMiddleware, DeleteEvent)
+	event.Post("/:id/rsvp", middlewares.AuthMiddleware, RSVPEvent)
+	event.Get("/:id/rsvp", middlewares.AuthMiddleware, GetMyRSVP)
+	event.Delete("/:id/rsvp", middlewares.AuthMiddleware, CancelRSVP)
+	event.Get("/:id/attendees", middlewares.AuthMiddleware, GetEventAttendees)
+	event.Post("/:id/check-in", middlewares.AuthMiddleware, CheckInAttendee)
+	event.Get("/:id/ics", middlewares.AuthMiddleware, GetEventICS)
+	event.Put("/:id/series", middlewares.AuthMiddleware, UpdateEventSeries)
+	event.Put("/:id/occurrences/:occurrence", middlewares.AuthMiddleware, UpdateEventOccurrence)
+	event.Delete("/:id/occurrences/:occurrence", middlewares.AuthMiddleware, DeleteEventOccurrence)
 	event.Post("/", middlewares.AuthMiddleware, AddNewEvent)
 }
 
@@ -29,6 +45,10 @@ type EventFilters struct {
 	EventType string `query:"event_type"`
 	// expects date in "2006-01-02" format; if not selected, this will be an empty string
 	StartDate string `query:"start_date"`
+	// Date window ("2006-01-02") over which recurring series are expanded;
+	// when either is set, results are ordered by start time
+	From      string `query:"from"`
+	To        string `query:"to"`
 	Page      int    `query:"page"`
 	Limit     int    `query:"limit"`
 }
@@ -43,15 +63,27 @@ type EventResponse struct {
 	StartDateTime      time.Time      `json:"start_date_time"`
 	EndDateTime        time.Time      `json:"end_date_time"`
 	IsRegistrationOpen bool           `json:"is_registration_open"`
+	Status             string         `json:"status"`
+	CancellationReason string         `json:"cancellation_reason,omitempty"`
+	// PENDING and REJECTED only appear on the poster's own events
+	ApprovalStatus     string         `json:"approval_status"`
+	ReviewNote         string         `json:"review_note,omitempty"`
 	// PosterResponse is defined in the jobs.go file under the user package
 	PostedBy           PosterResponse `json:"posted_by"`
 	CreatedAt          string         `json:"created_at"`
 	IsSaved            bool           `json:"is_saved"`
+	Capacity           *int           `json:"capacity"`
+	GoingCount         int64          `json:"going_count"`
+	// Empty when the user has not RSVPed, otherwise GOING or WAITLISTED
+	RSVPStatus         string         `json:"rsvp_status"`
+	RecurrenceRule     string         `json:"recurrence_rule,omitempty"`
+	// Set on occurrences of a series; SeriesID is the master event
+	SeriesID           string         `json:"series_id,omitempty"`
+	OccurrenceID       string         `json:"occurrence_id,omitempty"`
 }
 
 func GetEvents(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var filters EventFilters
 	queryString := c.Context().QueryArgs().String()
 	fmt.Println("Debug: URL Query Parameters:", queryString)
@@ -67,10 +99,20 @@ func GetEvents(c *fiber.Ctx) error {
 	if filters.Page == 0 { filters.Page = 1 }
 	if filters.Limit == 0 { filters.Limit = 10 }
 
+	from, to, windowed, err := parseEventWindow(filters.From, filters.To)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
 	session := database.Session.Db
 
+	// Campus events awaiting review stay hidden until approved
 	query := session.Model(&database.Event{}).
 		Preload("User").
+		Where("approval_status = ?", database.EventApprovalApproved).
 		Order("start_date_time DESC")
 
 	// Apply filters
@@ -83,7 +125,7 @@ func GetEvents(c *fiber.Ctx) error {
 	if filters.EventType != "" {
 		query = query.Where("event_type = ?", filters.EventType)
 	}
-	if filters.StartDate != "" {
+	if filters.StartDate != "" && !windowed {
 		startDate, err := time.Parse("2006-01-02", filters.StartDate)
 		if err == nil {
 			query = query.Where("start_date_time >= ?", startDate)
@@ -92,17 +134,34 @@ func GetEvents(c *fiber.Ctx) error {
 
 	// Pagination
 	var total int64
-	query.Count(&total)
 	offset := (filters.Page - 1) * filters.Limit
-	query = query.Offset(offset).Limit(filters.Limit)
-
-	var events []database.Event
+	var events []eventOccurrence
 
-	if err := query.Find(&events).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"message": "Failed to fetch events",
-		})
+	if windowed {
+		// Series are expanded in memory, so the window is paginated here
+		occurrences, err := listEventsInWindow(query, from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to fetch events",
+			})
+		}
+		total = int64(len(occurrences))
+		if offset < len(occurrences) {
+			events = occurrences[offset:min(offset+filters.Limit, len(occurrences))]
+		}
+	} else {
+		query.Count(&total)
+		var rows []database.Event
+		if err := query.Offset(offset).Limit(filters.Limit).Find(&rows).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to fetch events",
+			})
+		}
+		for _, row := range rows {
+			events = append(events, singleOccurrence(row))
+		}
 	}
 
 	// Get saved event IDs
@@ -116,7 +175,7 @@ func GetEvents(c *fiber.Ctx) error {
     // Collect UserIDs for profile lookup
     var userIDs []string
     for _, event := range events {
-        userIDs = append(userIDs, event.User.ID)
+        userIDs = append(userIDs, event.Event.User.ID)
     }
 
     // Fetch user profiles in bulk
@@ -136,9 +195,15 @@ func GetEvents(c *fiber.Ctx) error {
         profileMap[profile.UserID] = profile
     }
 
+	eventIDs := make([]string, 0, len(events))
+	for _, event := range events {
+		eventIDs = append(eventIDs, event.Event.ID)
+	}
+	goingCounts, rsvpStatus := loadRSVPInfo(session, eventIDs, userID)
 
 	transformedEvents := make([]EventResponse, 0, len(events))
-	for _, event := range events {
+	for _, occurrence := range events {
+		event := occurrence.Event
 		userName := ""
 		if event.User.UserName != nil {
 			userName = *event.User.UserName
@@ -165,13 +230,23 @@ func GetEvents(c *fiber.Ctx) error {
 			Venue:              event.Venue,
 			EventType:          string(event.EventType),
 			RegisterLink:       event.RegisterLink,
-			StartDateTime:      event.StartDateTime,
-			EndDateTime:        event.EndDateTime,
+			StartDateTime:      occurrence.Start,
+			EndDateTime:        occurrence.End,
 			IsRegistrationOpen: event.IsRegistrationOpen,
+			Status:             string(event.Status),
+			CancellationReason: event.CancellationReason,
+			ApprovalStatus:     string(event.ApprovalStatus),
+			ReviewNote:         event.ReviewNote,
 			PostedBy: poster,
 			CreatedAt: event.CreatedAt.Format("2006-01-02"),
 			// containes is a helper function defined in the jobs.go file under the user package
 			IsSaved:   contains(savedEventIDs, event.ID),
+			Capacity:   event.Capacity,
+			GoingCount: goingCounts[event.ID],
+			RSVPStatus: rsvpStatus[event.ID],
+			RecurrenceRule: eventRecurrenceRule(event),
+			SeriesID:       eventSeriesID(event),
+			OccurrenceID:   occurrence.OccurrenceID,
 		})
 	}
 
@@ -189,8 +264,7 @@ func GetEvents(c *fiber.Ctx) error {
 }
 
 func GetSavedEvents(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var pagination struct {  
 		Page  int `query:"page"`  
 		Limit int `query:"limit"`  
@@ -248,6 +322,11 @@ func GetSavedEvents(c *fiber.Ctx) error {
 		profileMap[profile.UserID] = profile
 	}
 
+	eventIDs := make([]string, 0, len(savedEvents))
+	for _, savedEvent := range savedEvents {
+		eventIDs = append(eventIDs, savedEvent.EventID)
+	}
+	goingCounts, rsvpStatus := loadRSVPInfo(database.Session.Db, eventIDs, userID)
 	
 	transformedEvents := make([]EventResponse, 0, len(savedEvents))  
 	for _, savedEvent := range savedEvents {  
@@ -278,8 +357,17 @@ func GetSavedEvents(c *fiber.Ctx) error {
 			StartDateTime:      event.StartDateTime,  
 			EndDateTime:        event.EndDateTime,  
 			IsRegistrationOpen: event.IsRegistrationOpen,  
+			Status:             string(event.Status),
+			CancellationReason: event.CancellationReason,
+			ApprovalStatus:     string(event.ApprovalStatus),
+			ReviewNote:         event.ReviewNote,
 			PostedBy: poster,
 			IsSaved: true,  
+			Capacity:   event.Capacity,
+			GoingCount: goingCounts[event.ID],
+			RSVPStatus: rsvpStatus[event.ID],
+			RecurrenceRule: eventRecurrenceRule(event),
+			SeriesID:       eventSeriesID(event),
 		})  
 	}  
 	
@@ -297,8 +385,7 @@ func GetSavedEvents(c *fiber.Ctx) error {
 }
 
 func GetMyEvents(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var pagination struct {  
 		Page  int `query:"page"`  
 		Limit int `query:"limit"`  
@@ -337,6 +424,12 @@ func GetMyEvents(c *fiber.Ctx) error {
         First(&userProfile).Error; err != nil {
         userProfile = database.UserProfile{}
     }
+
+	eventIDs := make([]string, 0, len(events))
+	for _, event := range events {
+		eventIDs = append(eventIDs, event.ID)
+	}
+	goingCounts, rsvpStatus := loadRSVPInfo(database.Session.Db, eventIDs, userID)
 	
 	transformedEvents := make([]EventResponse, 0, len(events))  
 	for _, event := range events {  
@@ -355,6 +448,10 @@ func GetMyEvents(c *fiber.Ctx) error {
 			StartDateTime:      event.StartDateTime,  
 			EndDateTime:        event.EndDateTime,  
 			IsRegistrationOpen: event.IsRegistrationOpen,  
+			Status:             string(event.Status),
+			CancellationReason: event.CancellationReason,
+			ApprovalStatus:     string(event.ApprovalStatus),
+			ReviewNote:         event.ReviewNote,
 			PostedBy: PosterResponse{  
 				ID:           event.User.ID,  
 				FullName:     event.User.FullName,  
@@ -362,6 +459,11 @@ func GetMyEvents(c *fiber.Ctx) error {
 				ProfileImage: userProfile.ProfileImage,  
 			},  
 			CreatedAt: event.CreatedAt.Format("2006-01-02"),  
+			Capacity:   event.Capacity,
+			GoingCount: goingCounts[event.ID],
+			RSVPStatus: rsvpStatus[event.ID],
+			RecurrenceRule: eventRecurrenceRule(event),
+			SeriesID:       eventSeriesID(event),
 		})  
 	}  
 	
@@ -379,8 +481,7 @@ func GetMyEvents(c *fiber.Ctx) error {
 }
 
 func SaveEvent(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var req struct {  
 		EventID string `json:"event_id"`  
 	}  
@@ -407,7 +508,7 @@ func SaveEvent(c *fiber.Ctx) error {
 	
 	// Verify event exists  
 	var event database.Event  
-	if err := database.Session.Db.Where("id = ?", req.EventID).First(&event).Error; err != nil {  
+	if err := database.Session.Db.Where("id = ? AND approval_status = ?", req.EventID, database.EventApprovalApproved).First(&event).Error; err != nil {  
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{  
 			"success": false,  
 			"message": "Invalid event ID",  
@@ -425,13 +526,16 @@ func SaveEvent(c *fiber.Ctx) error {
 			"message": "Failed to save event",  
 		})  
 	}  
+
+	if err := scheduleNextEventReminders(database.Session.Db, event, userID); err != nil {
+		fmt.Println("Failed to schedule event reminders:", err)
+	}
 	
 	return c.JSON(fiber.Map{"success": true, "action": "saved"})  
 }
 
 func DeleteEvent(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	eventID := c.Params("id")
 	// Verify ownership
 	result := database.Session.Db.
@@ -448,17 +552,8 @@ func DeleteEvent(c *fiber.Ctx) error {
 }
 
 func AddNewEvent(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
-	var req struct {  
-		Title              string    `json:"title" validate:"required,min=5,max=255"`  
-		Description        string    `json:"description" validate:"required,min=10"`  
-		Venue              string    `json:"venue" validate:"required"`  
-		EventType          string    `json:"event_type" validate:"required,oneof=CAMPUS_EVENT ALUM_EVENT"`  
-		RegisterLink       string    `json:"register_link" validate:"omitempty,url"`  
-		StartDateTime      time.Time `json:"start_date_time" validate:"required"`  
-		EndDateTime        time.Time `json:"end_date_time" validate:"required,gtfield=StartDateTime"`  
-	}  
+	userID := middlewares.AuthUserID(c)
+	var req EventRequest
 	
 	if err := c.BodyParser(&req); err != nil {  
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})  
@@ -473,6 +568,14 @@ func AddNewEvent(c *fiber.Ctx) error {
 		})  
 	}  
 	
+	approvalStatus, err := eventApprovalFor(database.Session.Db, userID, database.EventType(req.EventType))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create event",
+		})
+	}
+
 	// Create event  
 	eventData := database.Event{  
 		Title:              req.Title,  
@@ -483,10 +586,29 @@ func AddNewEvent(c *fiber.Ctx) error {
 		StartDateTime:      req.StartDateTime,  
 		EndDateTime:        req.EndDateTime,  
 		IsRegistrationOpen: true,  
+		Status:             database.EventStatusScheduled,
+		ApprovalStatus:     approvalStatus,
+		Capacity:           req.Capacity,
 		PostedBy:           userID,  
 	}  
+	if req.RecurrenceRule != "" {
+		rule, _ := database.ParseRecurrenceRule(req.RecurrenceRule)
+		applyRecurrence(&eventData, rule)
+		for _, start := range req.RecurrenceExceptions {
+			eventData.Exceptions = append(eventData.Exceptions, database.EventException{OccurrenceStart: start.UTC()})
+		}
+	}
 	
-	if err := database.Session.Db.Create(&eventData).Error; err != nil {  
+	err = database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&eventData).Error; err != nil {
+			return err
+		}
+		if eventData.ApprovalStatus != database.EventApprovalPending {
+			return nil
+		}
+		return notifyEventReviewers(tx, eventData, fmt.Sprintf("\"%s\" is waiting for review.", eventData.Title))
+	})
+	if err != nil {  
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{  
 			"success": false,  
 			"message": "Failed to create event",  
@@ -501,14 +623,16 @@ func AddNewEvent(c *fiber.Ctx) error {
 			"venue":       eventData.Venue,  
 			"event_type":  eventData.EventType,  
 			"start_time":  eventData.StartDateTime,  
+			"recurrence_rule": eventData.RecurrenceRule,
+			"recurrence_end":  eventData.RecurrenceEnd,
+			"approval_status": eventData.ApprovalStatus,
 		},  
 	})  
 }
 
 
 func UpdateRegistrationStatus(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	eventID := c.Params("id")
 	var req struct {  
 		IsOpen bool `json:"is_open"`  
@@ -539,16 +663,25 @@ func UpdateRegistrationStatus(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true, "is_open": event.IsRegistrationOpen})  
 }
 
+// EventRequest is the body accepted when creating an event.
+type EventRequest struct {
+	Title              string    `json:"title" validate:"required,min=5,max=255"`
+	Description        string    `json:"description" validate:"required,min=10"`
+	Venue              string    `json:"venue" validate:"required"`
+	EventType          string    `json:"event_type" validate:"required,oneof=CAMPUS_EVENT ALUM_EVENT"`
+	RegisterLink       string    `json:"register_link" validate:"omitempty,url"`
+	StartDateTime      time.Time `json:"start_date_time" validate:"required"`
+	EndDateTime        time.Time `json:"end_date_time" validate:"required,gtfield=StartDateTime"`
+	// Capacity limits in-app RSVPs; nil means unlimited
+	Capacity           *int      `json:"capacity" validate:"omitempty,min=1"`
+	// RRULE subset, e.g. "FREQ=WEEKLY;COUNT=10"; empty for a one-off event
+	RecurrenceRule       string      `json:"recurrence_rule"`
+	// Occurrence start times to leave out of the series (EXDATE)
+	RecurrenceExceptions []time.Time `json:"recurrence_exceptions"`
+}
+
 // Helper functions
-func validateEventRequest(req struct {
-		Title              string    `json:"title" validate:"required,min=5,max=255"`  
-		Description        string    `json:"description" validate:"required,min=10"`  
-		Venue              string    `json:"venue" validate:"required"`  
-		EventType          string    `json:"event_type" validate:"required,oneof=CAMPUS_EVENT ALUM_EVENT"`  
-		RegisterLink       string    `json:"register_link" validate:"omitempty,url"`  
-		StartDateTime      time.Time `json:"start_date_time" validate:"required"`  
-		EndDateTime        time.Time `json:"end_date_time" validate:"required,gtfield=StartDateTime"`  
-	}) map[string]string {
+func validateEventRequest(req EventRequest) map[string]string {
 	errors := make(map[string]string)
 	if strings.TrimSpace(req.Title) == "" {  
 		errors["title"] = "Title is required"  
@@ -567,9 +700,43 @@ func validateEventRequest(req struct {
 			errors["register_link"] = "Invalid URL format"  
 		}  
 	}  
+
+	if req.Capacity != nil && *req.Capacity < 1 {
+		errors["capacity"] = "Capacity must be at least 1"
+	}
+
+	if req.RecurrenceRule != "" {
+		rule, err := database.ParseRecurrenceRule(req.RecurrenceRule)
+		if err != nil {
+			errors["recurrence_rule"] = err.Error()
+		} else {
+			if rule.Until != nil && rule.Until.Before(req.StartDateTime) {
+				errors["recurrence_rule"] = "UNTIL must be after the start time"
+			}
+			for _, exception := range req.RecurrenceExceptions {
+				if !rule.IsOccurrence(req.StartDateTime, exception) {
+					errors["recurrence_exceptions"] = "Exceptions must match occurrence start times"
+					break
+				}
+			}
+		}
+	} else if len(req.RecurrenceExceptions) > 0 {
+		errors["recurrence_exceptions"] = "Exceptions require a recurrence rule"
+	}
 	
 	return errors  
 }
 
+func eventRecurrenceRule(event database.Event) string {
+	if event.RecurrenceRule == nil {
+		return ""
+	}
+	return *event.RecurrenceRule
+}
 
-
+func eventSeriesID(event database.Event) string {
+	if event.SeriesID == nil {
+		return ""
+	}
+	return *event.SeriesID
+}
@@ -0,0 +1,336 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	icsProdID       = "-//GradSpace//Events//EN"
+	icsUIDDomain    = "gradspace"
+	icsDateTime     = "20060102T150405Z"
+	icsMaxLineBytes = 75
+)
+
+// Past events older than this are left out of calendar feeds.
+const calendarFeedHistory = 90 * 24 * time.Hour
+
+// How far ahead series without an end are written out.
+const calendarSeriesHorizon = 366 * 24 * time.Hour
+
+// GetEventICS downloads a single event as an .ics file. For a series the
+// file carries each occurrence, including edited ones.
+func GetEventICS(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	session := database.Session.Db
+	var event database.Event
+	if err := session.Preload("User").Preload("Exceptions").
+		Where("(approval_status = ? OR posted_by = ?)", database.EventApprovalApproved, userID).
+		First(&event, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Event not found",
+		})
+	}
+
+	events := []database.Event{event}
+	to := event.StartDateTime
+	if event.RecurrenceRule != nil {
+		overrides, err := loadSeriesOverrides(session, []string{event.ID})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to load event",
+			})
+		}
+		events = append(events, overrides...)
+		to = time.Now().Add(calendarSeriesHorizon)
+		if event.RecurrenceEnd != nil {
+			to = *event.RecurrenceEnd
+		}
+	}
+	occurrences, err := icsOccurrences(session, events, event.StartDateTime, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load event",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"event-%s.ics\"", event.ID))
+	return c.SendString(buildICS(occurrences, ""))
+}
+
+// GetCalendarFeedURL returns the user's private feed URL, creating the token
+// on first use.
+func GetCalendarFeedURL(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	session := database.Session.Db
+
+	var feed database.CalendarFeed
+	err := session.Where("user_id = ?", userID).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		token, tokenErr := generateFeedToken()
+		if tokenErr != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to create calendar feed",
+			})
+		}
+		feed = database.CalendarFeed{UserID: userID, Token: token}
+		err = session.Create(&feed).Error
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch calendar feed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    fiber.Map{"feed_url": calendarFeedURL(c, feed.Token)},
+	})
+}
+
+// ResetCalendarFeedURL issues a new feed token so previously shared URLs stop
+// working.
+func ResetCalendarFeedURL(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	token, err := generateFeedToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reset calendar feed",
+		})
+	}
+
+	session := database.Session.Db
+	result := session.Model(&database.CalendarFeed{}).Where("user_id = ?", userID).Update("token", token)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result = session.Create(&database.CalendarFeed{UserID: userID, Token: token})
+	}
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reset calendar feed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    fiber.Map{"feed_url": calendarFeedURL(c, token)},
+	})
+}
+
+// GetCalendarFeed serves the subscribable feed. Calendar apps cannot send the
+// auth cookie, so the secret token in the URL identifies the user. The feed
+// contains the user's saved events, events they RSVPed to and their own events.
+func GetCalendarFeed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+	session := database.Session.Db
+
+	var feed database.CalendarFeed
+	if err := session.Where("token = ?", token).First(&feed).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Calendar feed not found")
+	}
+
+	since := time.Now().Add(-calendarFeedHistory)
+	var events []database.Event
+	if err := session.Preload("User").Preload("Exceptions").
+		Where("(end_date_time >= ? OR (recurrence_rule IS NOT NULL AND (recurrence_end IS NULL OR recurrence_end >= ?)))", since, since).
+		Where(session.Where("posted_by = ?", feed.UserID).
+			Or("id IN (?)", session.Model(&database.SavedEvent{}).Select("event_id").Where("user_id = ?", feed.UserID)).
+			Or("id IN (?)", session.Model(&database.EventRSVP{}).Select("event_id").
+				Where("user_id = ? AND status <> ?", feed.UserID, database.RSVPStatusCancelled))).
+		Order("start_date_time").
+		Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load calendar feed")
+	}
+
+	// Edited occurrences of included series, unless already present
+	included := make(map[string]bool, len(events))
+	var seriesIDs []string
+	for _, event := range events {
+		included[event.ID] = true
+		if event.RecurrenceRule != nil {
+			seriesIDs = append(seriesIDs, event.ID)
+		}
+	}
+	overrides, err := loadSeriesOverrides(session, seriesIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load calendar feed")
+	}
+	for _, override := range overrides {
+		if !included[override.ID] {
+			events = append(events, override)
+		}
+	}
+	occurrences, err := icsOccurrences(session, events, since, time.Now().Add(calendarSeriesHorizon))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load calendar feed")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=900")
+	return c.SendString(buildICS(occurrences, "GradSpace Events"))
+}
+
+func loadSeriesOverrides(session *gorm.DB, seriesIDs []string) ([]database.Event, error) {
+	var overrides []database.Event
+	if len(seriesIDs) == 0 {
+		return overrides, nil
+	}
+	err := session.Preload("User").Where("series_id IN ?", seriesIDs).Find(&overrides).Error
+	return overrides, err
+}
+
+// icsOccurrences lists the entries of a calendar: one-off events and edited
+// occurrences as they are, and series expanded between from and to. Series
+// are written out occurrence by occurrence rather than as an RRULE, since
+// they repeat at the same wall-clock time in the event time zone, which a
+// UTC DTSTART can't express across daylight saving changes.
+func icsOccurrences(session *gorm.DB, events []database.Event, from, to time.Time) ([]eventOccurrence, error) {
+	var occurrences []eventOccurrence
+	var masters []database.Event
+	for _, event := range events {
+		if event.RecurrenceRule != nil {
+			masters = append(masters, event)
+		} else {
+			occurrences = append(occurrences, singleOccurrence(event))
+		}
+	}
+	if len(masters) == 0 {
+		return occurrences, nil
+	}
+
+	skip, err := loadSkippedOccurrences(session, masters)
+	if err != nil {
+		return nil, err
+	}
+	for _, master := range masters {
+		occurrences = append(occurrences, expandSeries(master, from, to, skip[master.ID])...)
+	}
+	return occurrences, nil
+}
+
+func calendarFeedURL(c *fiber.Ctx, token string) string {
+	return c.BaseURL() + "/api/v1/events/feed/" + token + ".ics"
+}
+
+func generateFeedToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// buildICS renders occurrences as an RFC 5545 VCALENDAR. Times are written in
+// UTC, which calendar clients convert to the viewer's zone.
+func buildICS(occurrences []eventOccurrence, calendarName string) string {
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:"+icsProdID)
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	if calendarName != "" {
+		writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText(calendarName))
+	}
+
+	now := time.Now().UTC().Format(icsDateTime)
+	for _, occurrence := range occurrences {
+		event := occurrence.Event
+		// An occurrence of a series is identified by the series and its
+		// original start, so an edited one replaces the generated one
+		uid := event.ID
+		if event.SeriesID != nil {
+			uid = *event.SeriesID + "-" + occurrence.OccurrenceID
+		} else if event.RecurrenceRule != nil {
+			uid = event.ID + "-" + occurrence.OccurrenceID
+		}
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, fmt.Sprintf("UID:%s@%s", uid, icsUIDDomain))
+		writeICSLine(&b, "DTSTAMP:"+now)
+		writeICSLine(&b, "DTSTART:"+occurrence.Start.UTC().Format(icsDateTime))
+		writeICSLine(&b, "DTEND:"+occurrence.End.UTC().Format(icsDateTime))
+		writeICSLine(&b, "LAST-MODIFIED:"+event.UpdatedAt.UTC().Format(icsDateTime))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(event.Title))
+		writeICSLine(&b, "DESCRIPTION:"+escapeICSText(event.Description))
+		writeICSLine(&b, "LOCATION:"+escapeICSText(event.Venue))
+		if event.RegisterLink != "" {
+			writeICSLine(&b, "URL:"+event.RegisterLink)
+		}
+		if event.User.FullName != "" {
+			writeICSLine(&b, fmt.Sprintf("ORGANIZER;CN=%s:mailto:%s", escapeICSParam(event.User.FullName), event.User.Email))
+		}
+		writeICSLine(&b, "CATEGORIES:"+string(event.EventType))
+		if event.Status == database.EventStatusCancelled {
+			writeICSLine(&b, "STATUS:CANCELLED")
+		} else {
+			writeICSLine(&b, "STATUS:CONFIRMED")
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// writeICSLine folds content lines longer than 75 octets (RFC 5545 3.1)
+// without splitting a UTF-8 sequence, and terminates them with CRLF.
+func writeICSLine(b *strings.Builder, line string) {
+	limit := icsMaxLineBytes
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isUTF8Start(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, leaving one octet less
+		limit = icsMaxLineBytes - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isUTF8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+var icsTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+func escapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+// escapeICSParam quotes parameter values that contain delimiters.
+func escapeICSParam(s string) string {
+	s = strings.ReplaceAll(s, `"`, "'")
+	if strings.ContainsAny(s, ";:,") {
+		return `"` + s + `"`
+	}
+	return s
+}
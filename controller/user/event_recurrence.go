@@ -0,0 +1,475 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Widest date window GetEvents will expand series over
+	maxEventWindow = 366 * 24 * time.Hour
+	// Default window when only "from" is given
+	defaultEventWindow = 31 * 24 * time.Hour
+)
+
+// occurrenceID identifies an occurrence by the UTC start time it was
+// generated for, in the same form as an ICS RECURRENCE-ID.
+func occurrenceID(t time.Time) string {
+	return t.UTC().Format(icsDateTime)
+}
+
+func parseOccurrenceID(id string) (time.Time, error) {
+	return time.Parse(icsDateTime, id)
+}
+
+// eventOccurrence is one dated entry in an event listing: a one-off event,
+// an edited occurrence, or a generated occurrence of a series.
+type eventOccurrence struct {
+	Event        database.Event
+	Start        time.Time
+	End          time.Time
+	OccurrenceID string
+}
+
+func singleOccurrence(event database.Event) eventOccurrence {
+	occurrence := eventOccurrence{Event: event, Start: event.StartDateTime, End: event.EndDateTime}
+	if event.OccurrenceStart != nil {
+		occurrence.OccurrenceID = occurrenceID(*event.OccurrenceStart)
+	} else if event.RecurrenceRule != nil {
+		occurrence.OccurrenceID = occurrenceID(event.StartDateTime)
+	}
+	return occurrence
+}
+
+// parseEventWindow reads the from/to dates (YYYY-MM-DD). windowed is false
+// when neither is given.
+func parseEventWindow(fromParam, toParam string) (from, to time.Time, windowed bool, err error) {
+	if fromParam == "" && toParam == "" {
+		return from, to, false, nil
+	}
+	loc := config.GetEventTimezone()
+
+	today := time.Now().In(loc)
+	from = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+	if fromParam != "" {
+		if from, err = time.ParseInLocation("2006-01-02", fromParam, loc); err != nil {
+			return from, to, false, errors.New("from must be a date in YYYY-MM-DD format")
+		}
+	}
+	to = from.Add(defaultEventWindow)
+	if toParam != "" {
+		if to, err = time.ParseInLocation("2006-01-02", toParam, loc); err != nil {
+			return from, to, false, errors.New("to must be a date in YYYY-MM-DD format")
+		}
+		// Include the whole of the last day
+		to = to.Add(24*time.Hour - time.Nanosecond)
+	}
+	if to.Before(from) {
+		return from, to, false, errors.New("to must not be before from")
+	}
+	if to.Sub(from) > maxEventWindow {
+		return from, to, false, errors.New("date window cannot exceed 366 days")
+	}
+	return from, to, true, nil
+}
+
+// listEventsInWindow returns every occurrence starting within the window,
+// expanding recurring series, ordered by start time. query carries the
+// caller's filters.
+func listEventsInWindow(query *gorm.DB, from, to time.Time) ([]eventOccurrence, error) {
+	var singles []database.Event
+	if err := query.Session(&gorm.Session{}).
+		Where("recurrence_rule IS NULL AND start_date_time BETWEEN ? AND ?", from, to).
+		Find(&singles).Error; err != nil {
+		return nil, err
+	}
+
+	var masters []database.Event
+	if err := query.Session(&gorm.Session{}).
+		Preload("Exceptions").
+		Where("recurrence_rule IS NOT NULL AND start_date_time <= ? AND (recurrence_end IS NULL OR recurrence_end >= ?)", to, from).
+		Find(&masters).Error; err != nil {
+		return nil, err
+	}
+
+	occurrences := make([]eventOccurrence, 0, len(singles))
+	for _, event := range singles {
+		occurrences = append(occurrences, singleOccurrence(event))
+	}
+
+	if len(masters) > 0 {
+		skip, err := loadSkippedOccurrences(database.Session.Db, masters)
+		if err != nil {
+			return nil, err
+		}
+		for _, master := range masters {
+			occurrences = append(occurrences, expandSeries(master, from, to, skip[master.ID])...)
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+	return occurrences, nil
+}
+
+// loadSkippedOccurrences returns, per series, the occurrence starts that must
+// not be generated: exceptions, and occurrences replaced by an edited row.
+func loadSkippedOccurrences(session *gorm.DB, masters []database.Event) (map[string]map[int64]bool, error) {
+	skip := make(map[string]map[int64]bool, len(masters))
+	masterIDs := make([]string, 0, len(masters))
+	for _, master := range masters {
+		skip[master.ID] = make(map[int64]bool)
+		for _, exception := range master.Exceptions {
+			skip[master.ID][exception.OccurrenceStart.Unix()] = true
+		}
+		masterIDs = append(masterIDs, master.ID)
+	}
+
+	var overrides []database.Event
+	if err := session.Select("series_id", "occurrence_start").
+		Where("series_id IN ?", masterIDs).
+		Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		skip[*override.SeriesID][override.OccurrenceStart.Unix()] = true
+	}
+	return skip, nil
+}
+
+func expandSeries(master database.Event, from, to time.Time, skip map[int64]bool) []eventOccurrence {
+	rule, err := database.ParseRecurrenceRule(*master.RecurrenceRule)
+	if err != nil {
+		return nil
+	}
+	duration := master.EndDateTime.Sub(master.StartDateTime)
+
+	var occurrences []eventOccurrence
+	for _, start := range rule.Occurrences(master.StartDateTime, from, to) {
+		if skip[start.Unix()] {
+			continue
+		}
+		occurrences = append(occurrences, eventOccurrence{
+			Event:        master,
+			Start:        start,
+			End:          start.Add(duration),
+			OccurrenceID: occurrenceID(start),
+		})
+	}
+	return occurrences
+}
+
+// loadOwnedSeries fetches the series master for the occurrence endpoints and
+// resolves the occurrence in the URL.
+func loadOwnedSeries(c *fiber.Ctx) (database.Event, database.RecurrenceRule, time.Time, error) {
+	userID := middlewares.AuthUserID(c)
+
+	var master database.Event
+	if err := database.Session.Db.
+		Where("id = ? AND posted_by = ? AND recurrence_rule IS NOT NULL", c.Params("id"), userID).
+		First(&master).Error; err != nil {
+		return master, database.RecurrenceRule{}, time.Time{}, fiber.NewError(fiber.StatusForbidden, "Not authorized or series not found")
+	}
+	rule, err := database.ParseRecurrenceRule(*master.RecurrenceRule)
+	if err != nil {
+		return master, rule, time.Time{}, err
+	}
+
+	var start time.Time
+	if occurrence := c.Params("occurrence"); occurrence != "" {
+		start, err = parseOccurrenceID(occurrence)
+		if err != nil || !rule.IsOccurrence(master.StartDateTime, start) {
+			return master, rule, start, fiber.NewError(fiber.StatusNotFound, "Occurrence not found")
+		}
+		var exceptions int64
+		database.Session.Db.Model(&database.EventException{}).
+			Where("event_id = ? AND occurrence_start = ?", master.ID, start).
+			Count(&exceptions)
+		if exceptions > 0 {
+			return master, rule, start, fiber.NewError(fiber.StatusNotFound, "Occurrence has been removed from the series")
+		}
+	}
+	return master, rule, start, nil
+}
+
+// UpdateEventOccurrence edits a single occurrence of a series. The edit is
+// stored as its own event row, so the rest of the series is unaffected.
+func UpdateEventOccurrence(c *fiber.Ctx) error {
+	master, _, occurrenceStart, err := loadOwnedSeries(c)
+	if err != nil {
+		return eventErrorResponse(c, err, "Failed to load series")
+	}
+
+	var req EventRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	// An occurrence keeps the series' type
+	req.EventType = string(master.EventType)
+	validationErrors := validateEventRequest(req)
+	if req.RecurrenceRule != "" || len(req.RecurrenceExceptions) > 0 {
+		validationErrors["recurrence_rule"] = "A single occurrence cannot have its own recurrence"
+	}
+	if len(validationErrors) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  validationErrors,
+		})
+	}
+
+	session := database.Session.Db
+	var override database.Event
+	err = session.Where("series_id = ? AND occurrence_start = ?", master.ID, occurrenceStart).First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		override = database.Event{
+			Title:              master.Title,
+			Description:        master.Description,
+			Venue:              master.Venue,
+			RegisterLink:       master.RegisterLink,
+			StartDateTime:      occurrenceStart,
+			EndDateTime:        occurrenceStart.Add(master.EndDateTime.Sub(master.StartDateTime)),
+			EventType:          master.EventType,
+			IsRegistrationOpen: master.IsRegistrationOpen,
+			Status:             master.Status,
+			ApprovalStatus:     master.ApprovalStatus,
+			PostedBy:           master.PostedBy,
+			SeriesID:           &master.ID,
+			OccurrenceStart:    &occurrenceStart,
+		}
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load occurrence",
+		})
+	}
+	before := override
+
+	override.Title = req.Title
+	override.Description = req.Description
+	override.Venue = req.Venue
+	override.RegisterLink = req.RegisterLink
+	override.StartDateTime = req.StartDateTime
+	override.EndDateTime = req.EndDateTime
+	override.Capacity = req.Capacity
+
+	err = session.Transaction(func(tx *gorm.DB) error {
+		// Changing what was approved, or editing a rejected occurrence, sends
+		// it through review again when needed
+		if eventContentChanged(before, override) || before.ApprovalStatus == database.EventApprovalRejected {
+			approval, err := eventApprovalFor(tx, middlewares.AuthUserID(c), override.EventType)
+			if err != nil {
+				return err
+			}
+			setEventApproval(&override, approval)
+		}
+		if err := tx.Save(&override).Error; err != nil {
+			return err
+		}
+		// A series still waiting for review covers its occurrences
+		if override.ApprovalStatus == database.EventApprovalPending && before.ApprovalStatus != database.EventApprovalPending &&
+			master.ApprovalStatus != database.EventApprovalPending {
+			message := fmt.Sprintf("An occurrence of \"%s\" was edited and is waiting for review.", override.Title)
+			return notifyEventReviewers(tx, override, message)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update occurrence",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id":              override.ID,
+			"series_id":       master.ID,
+			"occurrence_id":   occurrenceID(occurrenceStart),
+			"start_date_time": override.StartDateTime,
+			"end_date_time":   override.EndDateTime,
+			"approval_status": override.ApprovalStatus,
+		},
+	})
+}
+
+// DeleteEventOccurrence removes one occurrence from a series, discarding any
+// edits made to it.
+func DeleteEventOccurrence(c *fiber.Ctx) error {
+	master, _, occurrenceStart, err := loadOwnedSeries(c)
+	if err != nil {
+		return eventErrorResponse(c, err, "Failed to load series")
+	}
+
+	err = database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("series_id = ? AND occurrence_start = ?", master.ID, occurrenceStart).
+			Delete(&database.Event{}).Error; err != nil {
+			return err
+		}
+		exception := database.EventException{EventID: master.ID, OccurrenceStart: occurrenceStart}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&exception).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to remove occurrence",
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// UpdateEventSeries edits every occurrence of a series. Exceptions and edited
+// occurrences that no longer line up with the new schedule are dropped.
+func UpdateEventSeries(c *fiber.Ctx) error {
+	master, _, _, err := loadOwnedSeries(c)
+	if err != nil {
+		return eventErrorResponse(c, err, "Failed to load series")
+	}
+
+	var req EventRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	req.EventType = string(master.EventType)
+	validationErrors := validateEventRequest(req)
+	if req.RecurrenceRule == "" {
+		validationErrors["recurrence_rule"] = "Recurrence rule is required for a series"
+	}
+	if len(validationErrors) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  validationErrors,
+		})
+	}
+	rule, _ := database.ParseRecurrenceRule(req.RecurrenceRule)
+
+	var removedExceptions, removedOverrides int64
+	err = database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		before := master
+		master.Title = req.Title
+		master.Description = req.Description
+		master.Venue = req.Venue
+		master.RegisterLink = req.RegisterLink
+		master.StartDateTime = req.StartDateTime
+		master.EndDateTime = req.EndDateTime
+		master.Capacity = req.Capacity
+		applyRecurrence(&master, rule)
+
+		// Changing what was approved, including the schedule, or editing a
+		// rejected series sends it through review again when needed
+		if eventContentChanged(before, master) || *before.RecurrenceRule != *master.RecurrenceRule ||
+			before.ApprovalStatus == database.EventApprovalRejected {
+			approval, err := eventApprovalFor(tx, middlewares.AuthUserID(c), master.EventType)
+			if err != nil {
+				return err
+			}
+			setEventApproval(&master, approval)
+		}
+		if err := tx.Omit("Exceptions").Save(&master).Error; err != nil {
+			return err
+		}
+		if master.ApprovalStatus != before.ApprovalStatus {
+			if err := tx.Model(&database.Event{}).Where("series_id = ?", master.ID).
+				Update("approval_status", master.ApprovalStatus).Error; err != nil {
+				return err
+			}
+			if master.ApprovalStatus == database.EventApprovalPending {
+				message := fmt.Sprintf("\"%s\" was edited and is waiting for review again.", master.Title)
+				if err := notifyEventReviewers(tx, master, message); err != nil {
+					return err
+				}
+			}
+		}
+
+		var exceptions []database.EventException
+		if err := tx.Where("event_id = ?", master.ID).Find(&exceptions).Error; err != nil {
+			return err
+		}
+		for _, exception := range exceptions {
+			if rule.IsOccurrence(master.StartDateTime, exception.OccurrenceStart) {
+				continue
+			}
+			if err := tx.Delete(&exception).Error; err != nil {
+				return err
+			}
+			removedExceptions++
+		}
+
+		var overrides []database.Event
+		if err := tx.Select("id", "occurrence_start").Where("series_id = ?", master.ID).Find(&overrides).Error; err != nil {
+			return err
+		}
+		for _, override := range overrides {
+			if rule.IsOccurrence(master.StartDateTime, *override.OccurrenceStart) {
+				continue
+			}
+			if err := tx.Delete(&database.Event{}, "id = ?", override.ID).Error; err != nil {
+				return err
+			}
+			removedOverrides++
+		}
+
+		// Exceptions passed in the request are added to the existing ones
+		for _, start := range req.RecurrenceExceptions {
+			exception := database.EventException{EventID: master.ID, OccurrenceStart: start.UTC()}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&exception).Error; err != nil {
+				return err
+			}
+		}
+
+		next, ok, err := database.NextEventOccurrence(tx, master, time.Now())
+		if err != nil || !ok {
+			return err
+		}
+		return database.RescheduleEventReminders(tx, master.ID, next)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update series",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id":                  master.ID,
+			"recurrence_rule":     *master.RecurrenceRule,
+			"recurrence_end":      master.RecurrenceEnd,
+			"approval_status":     master.ApprovalStatus,
+			"removed_exceptions":  removedExceptions,
+			"removed_occurrences": removedOverrides,
+		},
+	})
+}
+
+// scheduleNextEventReminders queues a user's reminders for the next
+// occurrence of an event. Reminders for a series move on to the following
+// occurrence once sent.
+func scheduleNextEventReminders(tx *gorm.DB, event database.Event, userID string) error {
+	next, ok, err := database.NextEventOccurrence(tx, event, time.Now())
+	if err != nil || !ok {
+		return err
+	}
+	return database.ScheduleEventReminders(tx, event.ID, userID, next)
+}
+
+// applyRecurrence stores the canonical rule on a series master along with
+// the start of its last occurrence.
+func applyRecurrence(event *database.Event, rule database.RecurrenceRule) {
+	canonical := rule.String()
+	event.RecurrenceRule = &canonical
+	event.RecurrenceEnd = rule.LastOccurrence(event.StartDateTime)
+}
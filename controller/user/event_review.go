@@ -0,0 +1,283 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Roles allowed to post campus events without review, and to review them.
+var eventReviewerRoles = []string{"Faculty", "Admin"}
+
+// eventApprovalFor decides whether a new or re-typed event needs review:
+// only campus events from users who are not faculty or admin do.
+func eventApprovalFor(session *gorm.DB, userID string, eventType database.EventType) (database.EventApprovalStatus, error) {
+	if eventType != database.EventTypeCampus {
+		return database.EventApprovalApproved, nil
+	}
+	var user database.User
+	if err := session.Select("id", "role", "two_factor_enabled").First(&user, "id = ?", userID).Error; err != nil {
+		return "", err
+	}
+	if middlewares.HasRole(&user, eventReviewerRoles...) {
+		return database.EventApprovalApproved, nil
+	}
+	return database.EventApprovalPending, nil
+}
+
+// eventContentChanged reports whether an edit touches what a review approved:
+// the wording, venue, link or time of the event.
+func eventContentChanged(before, after database.Event) bool {
+	return before.Title != after.Title ||
+		before.Description != after.Description ||
+		before.Venue != after.Venue ||
+		before.RegisterLink != after.RegisterLink ||
+		!before.StartDateTime.Equal(after.StartDateTime) ||
+		!before.EndDateTime.Equal(after.EndDateTime)
+}
+
+// setEventApproval changes an event's approval status, clearing the previous
+// decision.
+func setEventApproval(event *database.Event, status database.EventApprovalStatus) {
+	if status == event.ApprovalStatus {
+		return
+	}
+	event.ApprovalStatus = status
+	event.ReviewedBy = nil
+	event.ReviewedAt = nil
+	event.ReviewNote = ""
+}
+
+// notifyEventReviewers tells every faculty member and admin that an event is
+// waiting for review. Admins without two-factor authentication can't review
+// yet, so they are left out.
+func notifyEventReviewers(tx *gorm.DB, event database.Event, message string) error {
+	var reviewerIDs []string
+	if err := tx.Model(&database.User{}).
+		Where("role IN ? AND (role <> ? OR two_factor_enabled) AND id <> ? AND anonymized_at IS NULL",
+			eventReviewerRoles, "Admin", event.PostedBy).
+		Pluck("id", &reviewerIDs).Error; err != nil {
+		return err
+	}
+	if len(reviewerIDs) == 0 {
+		return nil
+	}
+
+	notifications := make([]database.Notification, 0, len(reviewerIDs))
+	for _, reviewerID := range reviewerIDs {
+		notifications = append(notifications, database.Notification{
+			UserID:    reviewerID,
+			CreatorID: event.PostedBy,
+			Type:      database.NotificationTypeEventReviewRequested,
+			EventID:   &event.ID,
+			Message:   message,
+		})
+	}
+	return tx.Create(&notifications).Error
+}
+
+// GetPendingEvents lists campus events awaiting review, oldest first.
+func GetPendingEvents(c *fiber.Ctx) error {
+	var pagination struct {
+		Page  int `query:"page"`
+		Limit int `query:"limit"`
+	}
+	if err := c.QueryParser(&pagination); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid query"})
+	}
+	if pagination.Page == 0 {
+		pagination.Page = 1
+	}
+	if pagination.Limit == 0 {
+		pagination.Limit = 10
+	}
+
+	session := database.Session.Db
+	// An edited occurrence is reviewed on its own unless its whole series is
+	// still waiting, in which case the series' decision covers it
+	query := session.Model(&database.Event{}).
+		Where("approval_status = ?", database.EventApprovalPending).
+		Where("series_id IS NULL OR series_id NOT IN (?)",
+			session.Model(&database.Event{}).Select("id").Where("approval_status = ?", database.EventApprovalPending))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to count pending events",
+		})
+	}
+
+	var events []database.Event
+	if err := query.Preload("User").
+		Order("created_at").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch pending events",
+		})
+	}
+
+	userIDs := make([]string, 0, len(events))
+	for _, event := range events {
+		userIDs = append(userIDs, event.PostedBy)
+	}
+	profileMap := make(map[string]database.UserProfile)
+	if len(userIDs) > 0 {
+		var profiles []database.UserProfile
+		session.Where("user_id IN ?", userIDs).Find(&profiles)
+		for _, profile := range profiles {
+			profileMap[profile.UserID] = profile
+		}
+	}
+
+	pending := make([]fiber.Map, 0, len(events))
+	for _, event := range events {
+		userName := ""
+		if event.User.UserName != nil {
+			userName = *event.User.UserName
+		}
+		pending = append(pending, fiber.Map{
+			"id":              event.ID,
+			"title":           event.Title,
+			"description":     event.Description,
+			"venue":           event.Venue,
+			"event_type":      event.EventType,
+			"register_link":   event.RegisterLink,
+			"start_date_time": event.StartDateTime,
+			"end_date_time":   event.EndDateTime,
+			"recurrence_rule": eventRecurrenceRule(event),
+			"series_id":       event.SeriesID,
+			"submitted_at":    event.UpdatedAt,
+			"posted_by": fiber.Map{
+				"id":            event.User.ID,
+				"full_name":     event.User.FullName,
+				"username":      userName,
+				"role":          event.User.Role,
+				"batch":         event.User.Batch,
+				"department":    event.User.Department,
+				"profile_image": profileMap[event.PostedBy].ProfileImage,
+			},
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"events": pending,
+			"pagination": fiber.Map{
+				"total": total,
+				"page":  pagination.Page,
+				"limit": pagination.Limit,
+			},
+		},
+	})
+}
+
+// ReviewEvent approves or rejects a pending campus event and notifies the
+// submitter. A reason is required for rejections.
+func ReviewEvent(c *fiber.Ctx) error {
+	reviewerID := middlewares.AuthUserID(c)
+	eventID := c.Params("id")
+
+	var req struct {
+		Decision string `json:"decision"` // "approve" or "reject"
+		Reason   string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	var status database.EventApprovalStatus
+	var notificationType database.NotificationType
+	switch req.Decision {
+	case "approve":
+		status = database.EventApprovalApproved
+		notificationType = database.NotificationTypeEventApproved
+	case "reject":
+		if req.Reason == "" {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"success": false,
+				"errors":  fiber.Map{"reason": "Reason is required when rejecting an event"},
+			})
+		}
+		status = database.EventApprovalRejected
+		notificationType = database.NotificationTypeEventRejected
+	default:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  fiber.Map{"decision": "Decision must be approve or reject"},
+		})
+	}
+
+	session := database.Session.Db
+	var event database.Event
+	if err := session.First(&event, "id = ?", eventID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Event not found",
+		})
+	}
+	if event.ApprovalStatus != database.EventApprovalPending {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Event is not awaiting review",
+		})
+	}
+
+	now := time.Now()
+	err := session.Transaction(func(tx *gorm.DB) error {
+		// Edited occurrences of a series share its review
+		result := tx.Model(&database.Event{}).
+			Where("(id = ? OR series_id = ?) AND approval_status = ?", event.ID, event.ID, database.EventApprovalPending).
+			Updates(map[string]interface{}{
+				"approval_status": status,
+				"reviewed_by":     reviewerID,
+				"reviewed_at":     now,
+				"review_note":     req.Reason,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusConflict, "Event is not awaiting review")
+		}
+
+		message := fmt.Sprintf("Your event \"%s\" has been approved and is now listed.", event.Title)
+		if status == database.EventApprovalRejected {
+			message = fmt.Sprintf("Your event \"%s\" was not approved. Reason: %s", event.Title, req.Reason)
+		} else if req.Reason != "" {
+			message += " Note: " + req.Reason
+		}
+		notification := database.Notification{
+			UserID:    event.PostedBy,
+			CreatorID: reviewerID,
+			Type:      notificationType,
+			EventID:   &event.ID,
+			Message:   message,
+		}
+		return tx.Create(&notification).Error
+	})
+	if err != nil {
+		return eventErrorResponse(c, err, "Failed to review event")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id":              event.ID,
+			"approval_status": status,
+			"reviewed_at":     now,
+			"review_note":     req.Reason,
+		},
+	})
+}
@@ -0,0 +1,440 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+	"gradspaceBK/util"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// checkInTokenPurpose scopes the signature on attendee QR codes.
+const checkInTokenPurpose = "event_checkin"
+
+var errEventNotFound = errors.New("event not found")
+
+type EventAttendee struct {
+	UserID       string     `json:"user_id"`
+	FullName     string     `json:"full_name"`
+	UserName     string     `json:"username"`
+	Email        string     `json:"email"`
+	ProfileImage string     `json:"profile_image"`
+	Status       string     `json:"status"`
+	RespondedAt  time.Time  `json:"responded_at"`
+	CheckedInAt  *time.Time `json:"checked_in_at"`
+}
+
+// RSVPEvent reserves a seat, or a place on the waitlist when the event is full.
+// A cancelled RSVP can be renewed; it joins the back of the waitlist.
+func RSVPEvent(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	eventID := c.Params("id")
+
+	var rsvp database.EventRSVP
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		// Lock the event so concurrent RSVPs cannot overfill it
+		var event database.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, "id = ?", eventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errEventNotFound
+			}
+			return err
+		}
+		if event.ApprovalStatus != database.EventApprovalApproved {
+			return errEventNotFound
+		}
+		if event.Status == database.EventStatusCancelled {
+			return fiber.NewError(fiber.StatusBadRequest, "This event has been cancelled")
+		}
+		if !event.IsRegistrationOpen || event.HasEnded(time.Now()) {
+			return fiber.NewError(fiber.StatusBadRequest, "Registration is closed for this event")
+		}
+
+		err := tx.Where("event_id = ? AND user_id = ?", eventID, userID).First(&rsvp).Error
+		if err == nil && rsvp.Status != database.RSVPStatusCancelled {
+			return fiber.NewError(fiber.StatusConflict, "You have already responded to this event")
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		going, err := countGoing(tx, eventID)
+		if err != nil {
+			return err
+		}
+		status := database.RSVPStatusGoing
+		if event.Capacity != nil && going >= int64(*event.Capacity) {
+			status = database.RSVPStatusWaitlisted
+		}
+
+		rsvp.EventID = eventID
+		rsvp.UserID = userID
+		rsvp.Status = status
+		rsvp.RespondedAt = time.Now()
+		rsvp.CheckedInAt = nil
+		if err := tx.Save(&rsvp).Error; err != nil {
+			return err
+		}
+		return scheduleNextEventReminders(tx, event, userID)
+	})
+	if err != nil {
+		return eventErrorResponse(c, err, "Failed to RSVP")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    rsvpTicket(database.Session.Db, rsvp),
+	})
+}
+
+// CancelRSVP withdraws the user's RSVP. A freed seat goes to the earliest
+// waitlisted attendee, who is notified.
+func CancelRSVP(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	eventID := c.Params("id")
+
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		var event database.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, "id = ?", eventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errEventNotFound
+			}
+			return err
+		}
+
+		var rsvp database.EventRSVP
+		if err := tx.Where("event_id = ? AND user_id = ? AND status <> ?", eventID, userID, database.RSVPStatusCancelled).
+			First(&rsvp).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "You have not RSVPed to this event")
+			}
+			return err
+		}
+		if rsvp.CheckedInAt != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "You have already checked in to this event")
+		}
+
+		wasGoing := rsvp.Status == database.RSVPStatusGoing
+		if err := tx.Model(&rsvp).Update("status", database.RSVPStatusCancelled).Error; err != nil {
+			return err
+		}
+		if !wasGoing {
+			return nil
+		}
+		_, err := promoteFromWaitlist(tx, event)
+		return err
+	})
+	if err != nil {
+		return eventErrorResponse(c, err, "Failed to cancel RSVP")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "status": database.RSVPStatusCancelled})
+}
+
+// GetMyRSVP returns the user's RSVP with their waitlist position, or their
+// check-in token once they have a seat.
+func GetMyRSVP(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	eventID := c.Params("id")
+
+	var rsvp database.EventRSVP
+	if err := database.Session.Db.
+		Where("event_id = ? AND user_id = ?", eventID, userID).
+		First(&rsvp).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "You have not RSVPed to this event",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    rsvpTicket(database.Session.Db, rsvp),
+	})
+}
+
+// GetEventAttendees lists everyone who responded to an event. Only the
+// organizer can see it.
+func GetEventAttendees(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	eventID := c.Params("id")
+	session := database.Session.Db
+
+	var event database.Event
+	if err := session.Where("id = ? AND posted_by = ?", eventID, userID).First(&event).Error; err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Not authorized or event not found",
+		})
+	}
+
+	query := session.Preload("User").Where("event_id = ?", eventID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var rsvps []database.EventRSVP
+	if err := query.Order("status, responded_at").Find(&rsvps).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch attendees",
+		})
+	}
+
+	userIDs := make([]string, 0, len(rsvps))
+	for _, rsvp := range rsvps {
+		userIDs = append(userIDs, rsvp.UserID)
+	}
+	profileMap := make(map[string]database.UserProfile)
+	if len(userIDs) > 0 {
+		var profiles []database.UserProfile
+		session.Where("user_id IN ?", userIDs).Find(&profiles)
+		for _, profile := range profiles {
+			profileMap[profile.UserID] = profile
+		}
+	}
+
+	attendees := make([]EventAttendee, 0, len(rsvps))
+	counts := map[string]int{"going": 0, "waitlisted": 0, "cancelled": 0, "checked_in": 0}
+	for _, rsvp := range rsvps {
+		userName := ""
+		if rsvp.User.UserName != nil {
+			userName = *rsvp.User.UserName
+		}
+		attendees = append(attendees, EventAttendee{
+			UserID:       rsvp.UserID,
+			FullName:     rsvp.User.FullName,
+			UserName:     userName,
+			Email:        rsvp.User.Email,
+			ProfileImage: profileMap[rsvp.UserID].ProfileImage,
+			Status:       string(rsvp.Status),
+			RespondedAt:  rsvp.RespondedAt,
+			CheckedInAt:  rsvp.CheckedInAt,
+		})
+
+		switch rsvp.Status {
+		case database.RSVPStatusGoing:
+			counts["going"]++
+		case database.RSVPStatusWaitlisted:
+			counts["waitlisted"]++
+		case database.RSVPStatusCancelled:
+			counts["cancelled"]++
+		}
+		if rsvp.CheckedInAt != nil {
+			counts["checked_in"]++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"capacity":  event.Capacity,
+			"counts":    counts,
+			"attendees": attendees,
+		},
+	})
+}
+
+// CheckInAttendee marks an attendee as present from the token in their QR
+// code. Only the organizer can check people in.
+func CheckInAttendee(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	eventID := c.Params("id")
+	session := database.Session.Db
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Token is required",
+		})
+	}
+
+	var event database.Event
+	if err := session.Where("id = ? AND posted_by = ?", eventID, userID).First(&event).Error; err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Not authorized or event not found",
+		})
+	}
+
+	rsvpID, err := util.VerifySignedValue(checkInTokenPurpose, req.Token)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid check-in token",
+		})
+	}
+
+	var rsvp database.EventRSVP
+	if err := session.Preload("User").Where("id = ? AND event_id = ?", rsvpID, eventID).First(&rsvp).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Ticket is not valid for this event",
+		})
+	}
+	if rsvp.Status != database.RSVPStatusGoing {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Attendee is not confirmed (status: %s)", rsvp.Status),
+		})
+	}
+	if rsvp.CheckedInAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success":       false,
+			"message":       "Attendee has already checked in",
+			"checked_in_at": rsvp.CheckedInAt,
+		})
+	}
+
+	now := time.Now()
+	if err := session.Model(&rsvp).Update("checked_in_at", now).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to check in attendee",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"user_id":       rsvp.UserID,
+			"full_name":     rsvp.User.FullName,
+			"checked_in_at": now,
+		},
+	})
+}
+
+// promoteFromWaitlist fills any open seats from the waitlist in the order
+// people joined it and notifies each promoted attendee. The caller must hold
+// a lock on the event row.
+func promoteFromWaitlist(tx *gorm.DB, event database.Event) ([]database.EventRSVP, error) {
+	query := tx.Where("event_id = ? AND status = ?", event.ID, database.RSVPStatusWaitlisted).
+		Order("responded_at")
+	if event.Capacity != nil {
+		going, err := countGoing(tx, event.ID)
+		if err != nil {
+			return nil, err
+		}
+		openSeats := int64(*event.Capacity) - going
+		if openSeats <= 0 {
+			return nil, nil
+		}
+		query = query.Limit(int(openSeats))
+	}
+
+	var promoted []database.EventRSVP
+	if err := query.Find(&promoted).Error; err != nil {
+		return nil, err
+	}
+	for i := range promoted {
+		if err := tx.Model(&promoted[i]).Update("status", database.RSVPStatusGoing).Error; err != nil {
+			return nil, err
+		}
+		eventID := event.ID
+		notification := database.Notification{
+			UserID:    promoted[i].UserID,
+			CreatorID: event.PostedBy,
+			Type:      database.NotificationTypeEventPromoted,
+			EventID:   &eventID,
+			Message:   fmt.Sprintf("A seat opened up and you're now confirmed for \"%s\".", event.Title),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return nil, err
+		}
+	}
+	return promoted, nil
+}
+
+func countGoing(tx *gorm.DB, eventID string) (int64, error) {
+	var going int64
+	err := tx.Model(&database.EventRSVP{}).
+		Where("event_id = ? AND status = ?", eventID, database.RSVPStatusGoing).
+		Count(&going).Error
+	return going, err
+}
+
+// rsvpTicket describes an RSVP to its owner. Confirmed attendees get the
+// signed token to render as a QR code; waitlisted ones get their position.
+func rsvpTicket(session *gorm.DB, rsvp database.EventRSVP) fiber.Map {
+	ticket := fiber.Map{
+		"event_id":      rsvp.EventID,
+		"status":        rsvp.Status,
+		"responded_at":  rsvp.RespondedAt,
+		"checked_in_at": rsvp.CheckedInAt,
+	}
+	switch rsvp.Status {
+	case database.RSVPStatusGoing:
+		ticket["check_in_token"] = util.SignValue(checkInTokenPurpose, rsvp.ID)
+	case database.RSVPStatusWaitlisted:
+		var ahead int64
+		session.Model(&database.EventRSVP{}).
+			Where("event_id = ? AND status = ? AND responded_at < ?", rsvp.EventID, database.RSVPStatusWaitlisted, rsvp.RespondedAt).
+			Count(&ahead)
+		ticket["waitlist_position"] = ahead + 1
+	}
+	return ticket
+}
+
+// loadRSVPInfo returns the confirmed attendee count per event and the
+// current user's RSVP status for each event they responded to.
+func loadRSVPInfo(session *gorm.DB, eventIDs []string, userID string) (map[string]int64, map[string]string) {
+	goingCounts := make(map[string]int64)
+	myStatus := make(map[string]string)
+	if len(eventIDs) == 0 {
+		return goingCounts, myStatus
+	}
+
+	var counts []struct {
+		EventID string
+		Count   int64
+	}
+	session.Model(&database.EventRSVP{}).
+		Select("event_id, COUNT(*) AS count").
+		Where("event_id IN ? AND status = ?", eventIDs, database.RSVPStatusGoing).
+		Group("event_id").
+		Scan(&counts)
+	for _, count := range counts {
+		goingCounts[count.EventID] = count.Count
+	}
+
+	var rsvps []database.EventRSVP
+	session.Select("event_id", "status").
+		Where("event_id IN ? AND user_id = ? AND status <> ?", eventIDs, userID, database.RSVPStatusCancelled).
+		Find(&rsvps)
+	for _, rsvp := range rsvps {
+		myStatus[rsvp.EventID] = string(rsvp.Status)
+	}
+	return goingCounts, myStatus
+}
+
+func eventErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	var fiberErr *fiber.Error
+	switch {
+	case errors.Is(err, errEventNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Event not found",
+		})
+	case errors.As(err, &fiberErr):
+		return c.Status(fiberErr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": fiberErr.Message,
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": fallback,
+		})
+	}
+}
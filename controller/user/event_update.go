@@ -0,0 +1,330 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventPatchRequest holds the fields PATCH /events/:id may change; absent
+// fields are left as they are.
+type EventPatchRequest struct {
+	Title              *string    `json:"title"`
+	Description        *string    `json:"description"`
+	Venue              *string    `json:"venue"`
+	EventType          *string    `json:"event_type"`
+	RegisterLink       *string    `json:"register_link"`
+	StartDateTime      *time.Time `json:"start_date_time"`
+	EndDateTime        *time.Time `json:"end_date_time"`
+	Capacity           *int       `json:"capacity"` // null removes the limit
+	IsRegistrationOpen *bool      `json:"is_registration_open"`
+	Status             *string    `json:"status"`
+	CancellationReason *string    `json:"cancellation_reason"`
+}
+
+type EventChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// UpdateEvent applies a partial update. The merged event is validated as a
+// whole, and everyone following the event is notified when its time, venue
+// or status changes.
+func UpdateEvent(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	eventID := c.Params("id")
+
+	var patch EventPatchRequest
+	var present map[string]json.RawMessage
+	if err := c.BodyParser(&patch); err != nil || json.Unmarshal(c.Body(), &present) != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+
+	var changes []EventChange
+	var event database.Event
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND posted_by = ?", eventID, userID).
+			First(&event).Error; err != nil {
+			return fiber.NewError(fiber.StatusForbidden, "Not authorized or event not found")
+		}
+		before := event
+
+		req := EventRequest{
+			Title:         event.Title,
+			Description:   event.Description,
+			Venue:         event.Venue,
+			EventType:     string(event.EventType),
+			RegisterLink:  event.RegisterLink,
+			StartDateTime: event.StartDateTime,
+			EndDateTime:   event.EndDateTime,
+			Capacity:      event.Capacity,
+		}
+		if patch.Title != nil {
+			req.Title = *patch.Title
+		}
+		if patch.Description != nil {
+			req.Description = *patch.Description
+		}
+		if patch.Venue != nil {
+			req.Venue = *patch.Venue
+		}
+		if patch.EventType != nil {
+			req.EventType = *patch.EventType
+		}
+		if patch.RegisterLink != nil {
+			req.RegisterLink = *patch.RegisterLink
+		}
+		if patch.StartDateTime != nil {
+			req.StartDateTime = *patch.StartDateTime
+		}
+		if patch.EndDateTime != nil {
+			req.EndDateTime = *patch.EndDateTime
+		}
+		if _, ok := present["capacity"]; ok {
+			req.Capacity = patch.Capacity
+		}
+
+		validationErrors := validateEventRequest(req)
+		if event.RecurrenceRule != nil && !req.StartDateTime.Equal(event.StartDateTime) {
+			validationErrors["start_date_time"] = "Use PUT /events/:id/series to reschedule a series"
+		}
+		if patch.Status != nil && *patch.Status != string(database.EventStatusScheduled) &&
+			*patch.Status != string(database.EventStatusCancelled) {
+			validationErrors["status"] = "Status must be SCHEDULED or CANCELLED"
+		}
+		if req.Capacity != nil {
+			going, err := countGoing(tx, event.ID)
+			if err != nil {
+				return err
+			}
+			if int64(*req.Capacity) < going {
+				validationErrors["capacity"] = fmt.Sprintf("Capacity cannot be lower than the %d confirmed attendees", going)
+			}
+		}
+		if len(validationErrors) > 0 {
+			return &eventValidationError{validationErrors}
+		}
+
+		event.Title = req.Title
+		event.Description = req.Description
+		event.Venue = req.Venue
+		event.EventType = database.EventType(req.EventType)
+		event.RegisterLink = req.RegisterLink
+		event.StartDateTime = req.StartDateTime
+		event.EndDateTime = req.EndDateTime
+		event.Capacity = req.Capacity
+		if patch.IsRegistrationOpen != nil {
+			event.IsRegistrationOpen = *patch.IsRegistrationOpen
+		}
+		if patch.Status != nil && database.EventStatus(*patch.Status) != event.Status {
+			event.Status = database.EventStatus(*patch.Status)
+			if event.Status == database.EventStatusCancelled {
+				now := time.Now()
+				event.CancelledAt = &now
+				event.IsRegistrationOpen = false
+			} else {
+				event.CancelledAt = nil
+				event.CancellationReason = ""
+			}
+		}
+		if patch.CancellationReason != nil && event.Status == database.EventStatusCancelled {
+			event.CancellationReason = strings.TrimSpace(*patch.CancellationReason)
+		}
+
+		// Re-typing an event, changing what was approved, or editing one that
+		// was rejected sends it through review again when needed
+		if event.EventType != before.EventType || eventContentChanged(before, event) ||
+			before.ApprovalStatus == database.EventApprovalRejected {
+			approval, err := eventApprovalFor(tx, userID, event.EventType)
+			if err != nil {
+				return err
+			}
+			setEventApproval(&event, approval)
+		}
+
+		if err := tx.Omit("Exceptions").Save(&event).Error; err != nil {
+			return err
+		}
+		if event.ApprovalStatus != before.ApprovalStatus {
+			if err := tx.Model(&database.Event{}).Where("series_id = ?", event.ID).
+				Update("approval_status", event.ApprovalStatus).Error; err != nil {
+				return err
+			}
+			if event.ApprovalStatus == database.EventApprovalPending {
+				message := fmt.Sprintf("\"%s\" was edited and is waiting for review again.", event.Title)
+				if err := notifyEventReviewers(tx, event, message); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Reminders skipped while the event was cancelled are queued again
+		uncancelled := before.Status == database.EventStatusCancelled && event.Status != database.EventStatusCancelled
+		if !event.StartDateTime.Equal(before.StartDateTime) || uncancelled {
+			next, ok, err := database.NextEventOccurrence(tx, event, time.Now())
+			if err != nil {
+				return err
+			}
+			if ok {
+				if err := database.RescheduleEventReminders(tx, event.ID, next); err != nil {
+					return err
+				}
+			}
+		}
+		if capacityRaised(before.Capacity, event.Capacity) {
+			if _, err := promoteFromWaitlist(tx, event); err != nil {
+				return err
+			}
+		}
+
+		changes = diffEvent(before, event)
+		return notifyEventFollowers(tx, event, changes)
+	})
+	if err != nil {
+		if validationErr, ok := err.(*eventValidationError); ok {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"success": false,
+				"errors":  validationErr.errors,
+			})
+		}
+		return eventErrorResponse(c, err, "Failed to update event")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id":                   event.ID,
+			"title":                event.Title,
+			"venue":                event.Venue,
+			"event_type":           event.EventType,
+			"start_date_time":      event.StartDateTime,
+			"end_date_time":        event.EndDateTime,
+			"capacity":             event.Capacity,
+			"is_registration_open": event.IsRegistrationOpen,
+			"status":               event.Status,
+			"cancellation_reason":  event.CancellationReason,
+			"approval_status":      event.ApprovalStatus,
+			"changes":              changes,
+		},
+	})
+}
+
+type eventValidationError struct {
+	errors map[string]string
+}
+
+func (e *eventValidationError) Error() string {
+	return "event validation failed"
+}
+
+func capacityRaised(before, after *int) bool {
+	if after == nil {
+		return before != nil
+	}
+	return before != nil && *after > *before
+}
+
+// diffEvent lists the changes attendees care about: time, venue and status.
+func diffEvent(before, after database.Event) []EventChange {
+	loc := config.GetEventTimezone()
+	format := func(t time.Time) string {
+		return t.In(loc).Format("Mon, 02 Jan 2006 3:04 PM MST")
+	}
+
+	changes := make([]EventChange, 0)
+	if !before.StartDateTime.Equal(after.StartDateTime) {
+		changes = append(changes, EventChange{"start_date_time", format(before.StartDateTime), format(after.StartDateTime)})
+	}
+	if !before.EndDateTime.Equal(after.EndDateTime) {
+		changes = append(changes, EventChange{"end_date_time", format(before.EndDateTime), format(after.EndDateTime)})
+	}
+	if before.Venue != after.Venue {
+		changes = append(changes, EventChange{"venue", before.Venue, after.Venue})
+	}
+	if before.Status != after.Status {
+		changes = append(changes, EventChange{"status", string(before.Status), string(after.Status)})
+	}
+	return changes
+}
+
+// notifyEventFollowers tells everyone who saved the event or holds an active
+// RSVP about the changes, except the organizer.
+func notifyEventFollowers(tx *gorm.DB, event database.Event, changes []EventChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var followerIDs []string
+	if err := tx.Model(&database.SavedEvent{}).
+		Where("event_id = ? AND user_id <> ?", event.ID, event.PostedBy).
+		Pluck("user_id", &followerIDs).Error; err != nil {
+		return err
+	}
+	var attendeeIDs []string
+	if err := tx.Model(&database.EventRSVP{}).
+		Where("event_id = ? AND user_id <> ? AND status <> ?", event.ID, event.PostedBy, database.RSVPStatusCancelled).
+		Pluck("user_id", &attendeeIDs).Error; err != nil {
+		return err
+	}
+	recipients := make(map[string]bool)
+	for _, id := range append(followerIDs, attendeeIDs...) {
+		recipients[id] = true
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	cancelled := false
+	for _, change := range changes {
+		if change.Field == "status" && change.To == string(database.EventStatusCancelled) {
+			cancelled = true
+		}
+	}
+
+	notificationType := database.NotificationTypeEventUpdated
+	var message string
+	if cancelled {
+		notificationType = database.NotificationTypeEventCancelled
+		message = fmt.Sprintf("\"%s\" has been cancelled.", event.Title)
+		if event.CancellationReason != "" {
+			message += " Reason: " + event.CancellationReason
+		}
+	} else {
+		parts := make([]string, 0, len(changes))
+		for _, change := range changes {
+			parts = append(parts, fmt.Sprintf("%s: %s → %s", eventChangeLabels[change.Field], change.From, change.To))
+		}
+		message = fmt.Sprintf("\"%s\" was updated. %s", event.Title, strings.Join(parts, "; "))
+	}
+
+	notifications := make([]database.Notification, 0, len(recipients))
+	for recipientID := range recipients {
+		eventID := event.ID
+		notifications = append(notifications, database.Notification{
+			UserID:    recipientID,
+			CreatorID: event.PostedBy,
+			Type:      notificationType,
+			EventID:   &eventID,
+			Message:   message,
+		})
+	}
+	return tx.Create(&notifications).Error
+}
+
+var eventChangeLabels = map[string]string{
+	"start_date_time": "Starts",
+	"end_date_time":   "Ends",
+	"venue":           "Venue",
+	"status":          "Status",
+}
@@ -6,9 +6,9 @@ import (
 	"gradspaceBK/middlewares"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -16,11 +16,16 @@ func JobRoutes(base *fiber.Group) {
     job := base.Group("/jobs")
     job.Get("/",middlewares.AuthMiddleware , GetJobs)
     job.Get("/saved",middlewares.AuthMiddleware ,GetSavedJobs)
+    job.Get("/recommended",middlewares.AuthMiddleware ,GetRecommendedJobs)
     job.Post("/save",middlewares.AuthMiddleware ,SaveJob)
     job.Patch("/:id/status",middlewares.AuthMiddleware,UpdateHiringStatus)
+    job.Post("/:id/report",middlewares.AuthMiddleware,ReportJob)
     job.Get("/my-jobs",middlewares.AuthMiddleware ,GetMyJobs)
     job.Delete("/:id",middlewares.AuthMiddleware, DeleteJob)
-    job.Post("/",middlewares.AuthMiddleware, AddNewJob)
+    job.Post("/",middlewares.AuthMiddleware,
+        middlewares.RateLimit("add_job", middlewares.Limit{Count: 10, Period: time.Hour}, middlewares.Limit{Count: 30, Period: time.Hour}),
+        AddNewJob)
+    job.Post("/import",middlewares.AuthMiddleware, middlewares.RequireRoles("Admin", "Faculty"), ImportJobs)
 }
 
 type JobFilters struct {
@@ -39,6 +44,7 @@ type JobResponse struct {
     Location     string          `json:"location"`
     Requirements string          `json:"requirements"`
     IsOpen       bool            `json:"is_open"`
+    IsHidden     bool            `json:"is_hidden"`
     JobType      string          `json:"job_type"`
     ApplyLink    string          `json:"apply_link"`
     Company      CompanyResponse `json:"company"`
@@ -65,9 +71,8 @@ type Pagination struct {
 }
 
 func GetJobs(c *fiber.Ctx) error {
-    user_data := c.Locals("user_data").(jwt.MapClaims)
-    userID, ok := user_data["user_id"].(string)
-    if !ok || userID == "" {
+    userID := middlewares.AuthUserID(c)
+    if userID == "" {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "success": false,
             "message": "Unauthorized: missing user ID",
@@ -90,7 +95,8 @@ func GetJobs(c *fiber.Ctx) error {
 
     query := session.Model(&database.Job{}).
         Preload("Company").
-        Preload("PostedByUser")
+        Preload("PostedByUser").
+        Where("is_hidden = ?", false)
 
     
     if filters.Search != "" {
@@ -204,9 +210,8 @@ func GetJobs(c *fiber.Ctx) error {
 }
 
 func GetSavedJobs(c *fiber.Ctx) error {
-	user_data := c.Locals("user_data").(jwt.MapClaims)
-    userID, ok := user_data["user_id"].(string)
-    if !ok || userID == "" {
+    userID := middlewares.AuthUserID(c)
+    if userID == "" {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "success": false,
             "message": "Unauthorized: missing user ID",
@@ -236,7 +241,8 @@ func GetSavedJobs(c *fiber.Ctx) error {
     query := database.Session.Db.
         Preload("Job.Company").
         Preload("Job.PostedByUser").
-        Where("user_id = ?", userID)
+        Joins("JOIN jobs ON jobs.id = saved_jobs.job_id AND jobs.is_hidden = ?", false).
+        Where("saved_jobs.user_id = ?", userID)
 
     var total int64
     if err := query.Model(&database.SavedJob{}).Count(&total).Error; err != nil {
@@ -324,9 +330,8 @@ func GetSavedJobs(c *fiber.Ctx) error {
 }
 
 func GetMyJobs(c *fiber.Ctx) error {
-	user_data := c.Locals("user_data").(jwt.MapClaims)
-    userID, ok := user_data["user_id"].(string)
-    if !ok || userID == "" {
+    userID := middlewares.AuthUserID(c)
+    if userID == "" {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "success": false,
             "message": "Unauthorized: missing user ID",
@@ -394,6 +399,7 @@ func GetMyJobs(c *fiber.Ctx) error {
             Location:     job.Location,
             Requirements: job.Requirements,
             IsOpen:       job.IsOpen,
+            IsHidden:     job.IsHidden,
             JobType:      job.JobType,
             ApplyLink:    job.ApplyLink,
             Company: CompanyResponse{
@@ -424,9 +430,8 @@ func GetMyJobs(c *fiber.Ctx) error {
 }
 
 func SaveJob(c *fiber.Ctx) error {
-	user_data := c.Locals("user_data").(jwt.MapClaims)
-    userID, ok := user_data["user_id"].(string)
-    if !ok || userID == "" {
+    userID := middlewares.AuthUserID(c)
+    if userID == "" {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "success": false,
             "message": "Unauthorized: missing user ID",
@@ -470,7 +475,7 @@ func SaveJob(c *fiber.Ctx) error {
 	fmt.Println(newSaved.JobID)
 	// check the jobid job present in job table
 	var job database.Job
-	if err := database.Session.Db.Where("id = ?", req.JobID).First(&job).Error; err != nil {
+	if err := database.Session.Db.Where("id = ? AND is_hidden = ?", req.JobID, false).First(&job).Error; err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid job ID",
@@ -489,9 +494,8 @@ func SaveJob(c *fiber.Ctx) error {
 }
 
 func DeleteJob(c *fiber.Ctx) error {
-	user_data := c.Locals("user_data").(jwt.MapClaims)
-    userID, ok := user_data["user_id"].(string)
-    if !ok || userID == "" {
+    userID := middlewares.AuthUserID(c)
+    if userID == "" {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "success": false,
             "message": "Unauthorized: missing user ID",
@@ -515,9 +519,8 @@ func DeleteJob(c *fiber.Ctx) error {
 }
 
 func AddNewJob(c *fiber.Ctx) error {
-    user_data := c.Locals("user_data").(jwt.MapClaims)
-    userID, ok := user_data["user_id"].(string)
-    if !ok || userID == "" {
+    userID := middlewares.AuthUserID(c)
+    if userID == "" {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "success": false,
             "message": "Unauthorized: missing user ID",
@@ -582,6 +585,10 @@ func AddNewJob(c *fiber.Ctx) error {
         })
     }
 
+    if err := notifyCompanyFollowers(database.Session.Db, jobData, company.Name); err != nil {
+        fmt.Println("Failed to notify company followers:", err)
+    }
+
     return c.Status(fiber.StatusCreated).JSON(fiber.Map{
         "success": true,
         "data": fiber.Map{
@@ -595,9 +602,8 @@ func AddNewJob(c *fiber.Ctx) error {
 }
 
 func UpdateHiringStatus(c *fiber.Ctx) error {
-	user_data := c.Locals("user_data").(jwt.MapClaims)
-    userID, ok := user_data["user_id"].(string)
-    if !ok || userID == "" {
+    userID := middlewares.AuthUserID(c)
+    if userID == "" {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "success": false,
             "message": "Unauthorized: missing user ID",
@@ -638,6 +644,14 @@ func UpdateHiringStatus(c *fiber.Ctx) error {
 
     return c.JSON(fiber.Map{"success": true, "is_open": job.IsOpen})
 }
+// ValidJobTypes mirrors the chk_job_type constraint on jobs.
+var ValidJobTypes = map[string]bool{
+    "Part-Time":  true,
+    "Full-Time":  true,
+    "Internship": true,
+    "Freelance":  true,
+}
+
 // Helper function for validation
 func validateJobRequest(req struct {
     Title        string `json:"title" validate:"required,min=5,max=255"`
@@ -664,13 +678,7 @@ func validateJobRequest(req struct {
         errors["description"] = "Description must be at least 20 characters"
     }
 
-    validJobTypes := map[string]bool{
-        "Part-Time":  true,
-        "Full-Time":  true,
-        "Internship": true,
-        "Freelance":  true,
-    }
-    if !validJobTypes[req.JobType] {
+    if !ValidJobTypes[req.JobType] {
         errors["job_type"] = "Invalid job type specified"
     }
 
@@ -0,0 +1,351 @@
+package user
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxImportRows = 500
+
+var errJobAlreadyImported = errors.New("job already imported")
+
+type JobImportRow struct {
+	ExternalID   string `json:"external_id"`
+	Title        string `json:"title"`
+	Company      string `json:"company"`
+	Description  string `json:"description"`
+	Location     string `json:"location"`
+	Requirements string `json:"requirements"`
+	JobType      string `json:"job_type"`
+	ApplyLink    string `json:"apply_link"`
+}
+
+type JobImportResult struct {
+	Row        int               `json:"row"`
+	ExternalID string            `json:"external_id"`
+	Status     string            `json:"status"` // created, skipped or failed
+	JobID      string            `json:"job_id,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+// ImportJobs creates jobs in bulk from a CSV or JSON feed. Companies are
+// matched by name and created when missing. Rows whose external_id was
+// already imported are skipped, so the same feed can be uploaded again.
+func ImportJobs(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized: missing user ID",
+		})
+	}
+
+	rows, err := parseJobImport(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	if len(rows) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "No rows to import",
+		})
+	}
+	if len(rows) > maxImportRows {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("A single import can contain at most %d rows", maxImportRows),
+		})
+	}
+
+	session := database.Session.Db
+	companyCache := make(map[string]string)
+	seen := make(map[string]bool)
+
+	results := make([]JobImportResult, 0, len(rows))
+	summary := map[string]int{"created": 0, "skipped": 0, "failed": 0}
+	for i, row := range rows {
+		result := JobImportResult{Row: i + 1, ExternalID: strings.TrimSpace(row.ExternalID)}
+
+		if errs := validateImportRow(row); len(errs) > 0 {
+			result.Status = "failed"
+			result.Errors = errs
+		} else if seen[result.ExternalID] {
+			result.Status = "skipped"
+			result.Message = "Duplicate external_id in this import"
+		} else {
+			seen[result.ExternalID] = true
+			result = importJobRow(session, userID, row, result, companyCache)
+		}
+
+		summary[result.Status]++
+		results = append(results, result)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"summary": summary,
+			"rows":    results,
+		},
+	})
+}
+
+func importJobRow(session *gorm.DB, userID string, row JobImportRow, result JobImportResult, companyCache map[string]string) JobImportResult {
+	var existing database.Job
+	if err := session.Select("id").Where("external_ref = ?", result.ExternalID).First(&existing).Error; err == nil {
+		result.Status = "skipped"
+		result.JobID = existing.ID
+		result.Message = "Job already imported"
+		return result
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		result.Status = "failed"
+		result.Message = "Failed to check existing jobs"
+		return result
+	}
+
+	var job database.Job
+	err := session.Transaction(func(tx *gorm.DB) error {
+		companyID, err := findOrCreateCompany(tx, row.Company, companyCache)
+		if err != nil {
+			return err
+		}
+
+		externalRef := result.ExternalID
+		job = database.Job{
+			Title:        strings.TrimSpace(row.Title),
+			CompanyID:    companyID,
+			Description:  strings.TrimSpace(row.Description),
+			Location:     strings.TrimSpace(row.Location),
+			Requirements: strings.TrimSpace(row.Requirements),
+			JobType:      strings.TrimSpace(row.JobType),
+			ApplyLink:    strings.TrimSpace(row.ApplyLink),
+			ExternalRef:  &externalRef,
+			PostedBy:     userID,
+			IsOpen:       true,
+		}
+		if err := tx.Create(&job).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errJobAlreadyImported
+		} else if err != nil {
+			return err
+		}
+		result.JobID = job.ID
+		return nil
+	})
+	if err != nil {
+		// Companies created inside the failed transaction were rolled back
+		delete(companyCache, database.NormalizeCompanyName(row.Company))
+	}
+	// An overlapping upload of the same feed imported the row first
+	if errors.Is(err, errJobAlreadyImported) {
+		if err := session.Select("id").Where("external_ref = ?", result.ExternalID).First(&existing).Error; err == nil {
+			result.JobID = existing.ID
+		}
+		result.Status = "skipped"
+		result.Message = "Job already imported"
+		return result
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Message = "Failed to create job"
+		return result
+	}
+
+	if err := notifyCompanyFollowers(session, job, strings.TrimSpace(row.Company)); err != nil {
+		fmt.Println("Failed to notify company followers:", err)
+	}
+
+	result.Status = "created"
+	return result
+}
+
+// findOrCreateCompany matches a company by its normalized name.
+func findOrCreateCompany(tx *gorm.DB, name string, cache map[string]string) (string, error) {
+	name = strings.TrimSpace(name)
+	key := database.NormalizeCompanyName(name)
+	if id, ok := cache[key]; ok {
+		return id, nil
+	}
+
+	var company database.Company
+	err := tx.Where("normalized_name = ?", key).First(&company).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		company = database.Company{Name: name}
+		// In a savepoint, so losing a race with another import creating the
+		// same company doesn't abort the row's transaction
+		err = tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(&company).Error
+		})
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			err = tx.Where("normalized_name = ?", key).First(&company).Error
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cache[key] = company.ID
+	return company.ID, nil
+}
+
+func validateImportRow(row JobImportRow) map[string]string {
+	errors := make(map[string]string)
+
+	if strings.TrimSpace(row.ExternalID) == "" {
+		errors["external_id"] = "External ID is required"
+	}
+	if len(strings.TrimSpace(row.Title)) < 3 {
+		errors["title"] = "Title must be at least 3 characters"
+	}
+	if strings.TrimSpace(row.Company) == "" {
+		errors["company"] = "Company name is required"
+	}
+	if len(strings.TrimSpace(row.Description)) < 10 {
+		errors["description"] = "Description must be at least 10 characters"
+	}
+	if strings.TrimSpace(row.Location) == "" {
+		errors["location"] = "Location is required"
+	}
+	if strings.TrimSpace(row.Requirements) == "" {
+		errors["requirements"] = "Requirements are required"
+	}
+	if !ValidJobTypes[strings.TrimSpace(row.JobType)] {
+		errors["job_type"] = "Job type must be one of: Part-Time, Full-Time, Internship, Freelance"
+	}
+	if link := strings.TrimSpace(row.ApplyLink); link != "" {
+		if _, err := url.ParseRequestURI(link); err != nil {
+			errors["apply_link"] = "Invalid URL format"
+		}
+	}
+
+	return errors
+}
+
+// parseJobImport reads rows from an uploaded "file" form field or from the
+// raw request body. The format comes from the file extension, the "format"
+// query parameter or the Content-Type header, in that order.
+func parseJobImport(c *fiber.Ctx) ([]JobImportRow, error) {
+	var data []byte
+	format := strings.ToLower(c.Query("format"))
+
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return nil, errors.New("Failed to read uploaded file")
+		}
+		defer f.Close()
+		if data, err = io.ReadAll(f); err != nil {
+			return nil, errors.New("Failed to read uploaded file")
+		}
+		switch strings.ToLower(filepath.Ext(file.Filename)) {
+		case ".csv":
+			format = "csv"
+		case ".json":
+			format = "json"
+		}
+	} else {
+		data = c.Body()
+	}
+
+	if format == "" {
+		contentType := strings.ToLower(string(c.Request().Header.ContentType()))
+		switch {
+		case strings.Contains(contentType, "csv"):
+			format = "csv"
+		case strings.Contains(contentType, "json"):
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "csv":
+		return parseJobImportCSV(data)
+	case "json":
+		return parseJobImportJSON(data)
+	default:
+		return nil, errors.New("Unsupported import format, expected CSV or JSON")
+	}
+}
+
+// parseJobImportJSON accepts either a bare array of rows or {"jobs": [...]}.
+func parseJobImportJSON(data []byte) ([]JobImportRow, error) {
+	data = bytes.TrimSpace(data)
+	var rows []JobImportRow
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, errors.New("Invalid JSON")
+		}
+		return rows, nil
+	}
+
+	var wrapper struct {
+		Jobs []JobImportRow `json:"jobs"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, errors.New("Invalid JSON")
+	}
+	return wrapper.Jobs, nil
+}
+
+// parseJobImportCSV expects a header row using the JSON field names.
+func parseJobImportCSV(data []byte) ([]JobImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.New("Invalid CSV")
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"external_id", "title", "company", "job_type"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %q column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	rows := make([]JobImportRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		rows = append(rows, JobImportRow{
+			ExternalID:   field(record, "external_id"),
+			Title:        field(record, "title"),
+			Company:      field(record, "company"),
+			Description:  field(record, "description"),
+			Location:     field(record, "location"),
+			Requirements: field(record, "requirements"),
+			JobType:      field(record, "job_type"),
+			ApplyLink:    field(record, "apply_link"),
+		})
+	}
+	return rows, nil
+}
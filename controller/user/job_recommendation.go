@@ -0,0 +1,354 @@
+package user
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Weights used when scoring a job against a user. Skills found in the
+// requirements count more than skills that are only mentioned elsewhere.
+const (
+	skillRequirementWeight = 5
+	skillMentionWeight     = 3
+	interestWeight         = 2
+	departmentWeight       = 3
+	roleJobTypeWeight      = 2
+	savedJobTypeWeight     = 1
+	savedCompanyWeight     = 2
+	batchMentionWeight     = 1
+)
+
+// Years only restrict a job when they follow batch wording, e.g. "Batch: 2024,
+// 2025", "class of 2023" or "graduating in 2024-2026". Other years, such as
+// "experience since 2018", are only a weak signal.
+var (
+	batchListPattern  = regexp.MustCompile(`(?i)\b(?:batch(?:es)?(?: of)?|class(?:es)? of|graduating(?: batch(?:es)?| in| year)?|pass(?:ing)?[ -]?out(?: batch| year| in)?)\s*[:\-]?\s*((?:199[5-9]|20[0-9]{2})(?:\s*(?:,|/|&|-|to|and|or)\s*(?:199[5-9]|20[0-9]{2}))*)\b`)
+	batchYearPattern  = regexp.MustCompile(`\b(?:199[5-9]|20[0-9]{2})\b`)
+	batchRangePattern = regexp.MustCompile(`(?i)\b(199[5-9]|20[0-9]{2})\s*(?:-|to)\s*(199[5-9]|20[0-9]{2})\b`)
+)
+
+type JobMatch struct {
+	Score            int      `json:"score"`
+	MatchedSkills    []string `json:"matched_skills"`
+	MatchedInterests []string `json:"matched_interests"`
+	Reasons          []string `json:"reasons"`
+}
+
+type RecommendedJobResponse struct {
+	JobResponse
+	Match JobMatch `json:"match"`
+}
+
+// recommendationContext holds everything about the user that scoring needs,
+// so the per-job work does not touch the database.
+type recommendationContext struct {
+	Department    string
+	Batch         int
+	Role          string
+	Skills        []string
+	Interests     []string
+	SavedJobTypes map[string]int
+	SavedCompany  map[string]int
+}
+
+func GetRecommendedJobs(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized: missing user ID",
+		})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	session := database.Session.Db
+
+	var user database.User
+	if err := session.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	var profile database.UserProfile
+	session.Where("user_id = ?", userID).First(&profile)
+
+	ctx := recommendationContext{
+		Department:    user.Department,
+		Batch:         user.Batch,
+		Role:          user.Role,
+		SavedJobTypes: make(map[string]int),
+		SavedCompany:  make(map[string]int),
+	}
+	if len(profile.Skills) > 0 {
+		json.Unmarshal(profile.Skills, &ctx.Skills)
+	}
+	if len(profile.Interests) > 0 {
+		json.Unmarshal(profile.Interests, &ctx.Interests)
+	}
+
+	// Past saved jobs tell us which job types and companies the user likes
+	var savedJobs []database.SavedJob
+	if err := session.Preload("Job").Where("user_id = ?", userID).Find(&savedJobs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch saved jobs",
+		})
+	}
+	savedJobIDs := make([]string, 0, len(savedJobs))
+	for _, saved := range savedJobs {
+		savedJobIDs = append(savedJobIDs, saved.JobID)
+		ctx.SavedJobTypes[saved.Job.JobType]++
+		ctx.SavedCompany[saved.Job.CompanyID]++
+	}
+
+	query := session.Model(&database.Job{}).
+		Preload("Company").
+		Preload("PostedByUser").
+		Where("is_open = ? AND is_hidden = ? AND posted_by <> ?", true, false, userID)
+	if len(savedJobIDs) > 0 {
+		query = query.Where("id NOT IN ?", savedJobIDs)
+	}
+
+	var jobs []database.Job
+	if err := query.Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch jobs",
+		})
+	}
+
+	type scoredJob struct {
+		job   database.Job
+		match JobMatch
+	}
+	scored := make([]scoredJob, 0, len(jobs))
+	for _, job := range jobs {
+		match, eligible := scoreJob(ctx, job)
+		if !eligible || match.Score == 0 {
+			continue
+		}
+		scored = append(scored, scoredJob{job: job, match: match})
+	}
+
+	// Highest score first, newest first on ties
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].match.Score > scored[j].match.Score
+	})
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	var posterIDs []string
+	for _, s := range scored {
+		posterIDs = append(posterIDs, s.job.PostedBy)
+	}
+	var userProfiles []database.UserProfile
+	if len(posterIDs) > 0 {
+		if err := session.Where("user_id IN ?", posterIDs).Find(&userProfiles).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to fetch user profiles",
+			})
+		}
+	}
+	profileMap := make(map[string]database.UserProfile)
+	for _, p := range userProfiles {
+		profileMap[p.UserID] = p
+	}
+
+	recommendations := make([]RecommendedJobResponse, 0, len(scored))
+	for _, s := range scored {
+		job := s.job
+		userName := ""
+		if job.PostedByUser.UserName != nil {
+			userName = *job.PostedByUser.UserName
+		}
+		recommendations = append(recommendations, RecommendedJobResponse{
+			JobResponse: JobResponse{
+				ID:           job.ID,
+				Title:        job.Title,
+				Description:  job.Description,
+				Location:     job.Location,
+				Requirements: job.Requirements,
+				IsOpen:       job.IsOpen,
+				JobType:      job.JobType,
+				ApplyLink:    job.ApplyLink,
+				Company: CompanyResponse{
+					Name:    job.Company.Name,
+					LogoURL: job.Company.LogoURL,
+				},
+				PostedBy: PosterResponse{
+					ID:           job.PostedByUser.ID,
+					FullName:     job.PostedByUser.FullName,
+					UserName:     userName,
+					ProfileImage: profileMap[job.PostedBy].ProfileImage,
+				},
+				CreatedAt: job.CreatedAt.Format("2006-01-02"),
+			},
+			Match: s.match,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"jobs": recommendations,
+		},
+	})
+}
+
+// scoreJob scores a single job for the user. The second return value is false
+// when the job explicitly targets batches the user does not belong to.
+func scoreJob(ctx recommendationContext, job database.Job) (JobMatch, bool) {
+	match := JobMatch{
+		MatchedSkills:    []string{},
+		MatchedInterests: []string{},
+		Reasons:          []string{},
+	}
+
+	requirements := strings.ToLower(job.Requirements)
+	text := strings.ToLower(job.Title + " " + job.Description)
+
+	// Batch eligibility: if the requirements name the batches a job is open
+	// to, the user's batch must be one of them.
+	if ctx.Batch > 0 {
+		if batches := eligibleBatches(job.Requirements); len(batches) > 0 {
+			if !batches[ctx.Batch] {
+				return match, false
+			}
+			match.Reasons = append(match.Reasons, "Open to your batch ("+strconv.Itoa(ctx.Batch)+")")
+		} else if batchMentioned(job.Requirements, ctx.Batch) {
+			match.Score += batchMentionWeight
+			match.Reasons = append(match.Reasons, "Mentions your batch year ("+strconv.Itoa(ctx.Batch)+")")
+		}
+	}
+
+	for _, skill := range ctx.Skills {
+		s := strings.ToLower(strings.TrimSpace(skill))
+		if s == "" {
+			continue
+		}
+		if containsTerm(requirements, s) {
+			match.Score += skillRequirementWeight
+			match.MatchedSkills = append(match.MatchedSkills, skill)
+		} else if containsTerm(text, s) {
+			match.Score += skillMentionWeight
+			match.MatchedSkills = append(match.MatchedSkills, skill)
+		}
+	}
+	if len(match.MatchedSkills) > 0 {
+		match.Reasons = append(match.Reasons, "Matches your skills: "+strings.Join(match.MatchedSkills, ", "))
+	}
+
+	for _, interest := range ctx.Interests {
+		i := strings.ToLower(strings.TrimSpace(interest))
+		if i == "" {
+			continue
+		}
+		if containsTerm(requirements, i) || containsTerm(text, i) {
+			match.Score += interestWeight
+			match.MatchedInterests = append(match.MatchedInterests, interest)
+		}
+	}
+	if len(match.MatchedInterests) > 0 {
+		match.Reasons = append(match.Reasons, "Related to your interests: "+strings.Join(match.MatchedInterests, ", "))
+	}
+
+	if d := strings.ToLower(strings.TrimSpace(ctx.Department)); d != "" &&
+		(containsTerm(requirements, d) || containsTerm(text, d)) {
+		match.Score += departmentWeight
+		match.Reasons = append(match.Reasons, "Mentions your department ("+ctx.Department+")")
+	}
+
+	// Students are usually looking for internships, alumni for full-time roles
+	switch ctx.Role {
+	case "Student":
+		if job.JobType == "Internship" || job.JobType == "Part-Time" {
+			match.Score += roleJobTypeWeight
+			match.Reasons = append(match.Reasons, job.JobType+" role suited to students")
+		}
+	case "Alumni":
+		if job.JobType == "Full-Time" || job.JobType == "Freelance" {
+			match.Score += roleJobTypeWeight
+			match.Reasons = append(match.Reasons, job.JobType+" role suited to alumni")
+		}
+	}
+
+	if n := ctx.SavedJobTypes[job.JobType]; n > 0 {
+		match.Score += savedJobTypeWeight
+		match.Reasons = append(match.Reasons, "Similar to "+job.JobType+" jobs you saved")
+	}
+	if n := ctx.SavedCompany[job.CompanyID]; n > 0 {
+		match.Score += savedCompanyWeight
+		match.Reasons = append(match.Reasons, "You saved other jobs at "+job.Company.Name)
+	}
+
+	return match, true
+}
+
+// eligibleBatches returns the graduation years a job is restricted to, from
+// batch wording in its requirements. Ranges such as "2022-2024" include the
+// years between. An empty result means the job is open to every batch.
+func eligibleBatches(requirements string) map[int]bool {
+	batches := make(map[int]bool)
+	for _, list := range batchListPattern.FindAllStringSubmatch(requirements, -1) {
+		for _, r := range batchRangePattern.FindAllStringSubmatch(list[1], -1) {
+			from, _ := strconv.Atoi(r[1])
+			to, _ := strconv.Atoi(r[2])
+			for year := from; year <= to; year++ {
+				batches[year] = true
+			}
+		}
+		for _, y := range batchYearPattern.FindAllString(list[1], -1) {
+			year, _ := strconv.Atoi(y)
+			batches[year] = true
+		}
+	}
+	return batches
+}
+
+// batchMentioned reports whether the batch year appears anywhere in the text.
+func batchMentioned(text string, batch int) bool {
+	for _, y := range batchYearPattern.FindAllString(text, -1) {
+		if y == strconv.Itoa(batch) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsTerm reports whether term appears in text as a whole word, so that
+// a skill like "go" does not match "google".
+func containsTerm(text, term string) bool {
+	idx := 0
+	for {
+		i := strings.Index(text[idx:], term)
+		if i < 0 {
+			return false
+		}
+		start := idx + i
+		end := start + len(term)
+		if (start == 0 || !isWordChar(text[start-1])) && (end == len(text) || !isWordChar(text[end])) {
+			return true
+		}
+		idx = start + 1
+	}
+}
+
+func isWordChar(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
@@ -0,0 +1,144 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ValidReportReasons mirrors the chk_report_reason constraint on job_reports.
+var ValidReportReasons = map[string]bool{
+	"Fake Job":               true,
+	"Scam":                   true,
+	"Discriminatory Content": true,
+	"Incorrect Information":  true,
+}
+
+func ReportJob(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized: missing user ID",
+		})
+	}
+	jobID := c.Params("id")
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+		})
+	}
+	if !ValidReportReasons[req.Reason] {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors": fiber.Map{
+				"reason": "Reason must be one of: Fake Job, Scam, Discriminatory Content, Incorrect Information",
+			},
+		})
+	}
+
+	session := database.Session.Db
+
+	var job database.Job
+	if err := session.Where("id = ?", jobID).First(&job).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Job not found",
+		})
+	}
+	if job.PostedBy == userID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "You cannot report your own job",
+		})
+	}
+
+	var existing database.JobReport
+	if err := session.Where("job_id = ? AND reporter_id = ?", jobID, userID).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "You have already reported this job",
+		})
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to check existing reports",
+		})
+	}
+
+	hidden := false
+	err := session.Transaction(func(tx *gorm.DB) error {
+		report := database.JobReport{
+			JobID:       jobID,
+			Reason:      req.Reason,
+			JobPosterID: job.PostedBy,
+			ReporterID:  userID,
+			Status:      database.JobReportStatusPending,
+		}
+		if err := tx.Create(&report).Error; err != nil {
+			return err
+		}
+
+		if job.IsHidden {
+			return nil
+		}
+
+		var reporters int64
+		if err := tx.Model(&database.JobReport{}).
+			Where("job_id = ? AND status = ?", jobID, database.JobReportStatusPending).
+			Distinct("reporter_id").
+			Count(&reporters).Error; err != nil {
+			return err
+		}
+		if reporters < int64(config.GetJobReportHideThreshold()) {
+			return nil
+		}
+
+		// Enough distinct users flagged it: hide until an admin reviews
+		if err := tx.Model(&database.Job{}).Where("id = ?", jobID).Update("is_hidden", true).Error; err != nil {
+			return err
+		}
+		notification := database.Notification{
+			UserID:    job.PostedBy,
+			CreatorID: job.PostedBy,
+			Type:      database.NotificationTypeJobHidden,
+			JobID:     &job.ID,
+			Message:   fmt.Sprintf("Your job \"%s\" has been hidden after multiple reports and is awaiting review.", job.Title),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return err
+		}
+		hidden = true
+		return nil
+	})
+	// A concurrent report from the same user got in first
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "You have already reported this job",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to report job",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Job reported",
+		"hidden":  hidden,
+	})
+}
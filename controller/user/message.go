@@ -12,7 +12,6 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
@@ -20,7 +19,9 @@ func RegisterMessageRoutes(base *fiber.Group) {
 	msg := base.Group("/messages")
 	msg.Get("/conversations/", middlewares.AuthMiddleware, GetConversations)
 	msg.Get("/suggested/users", middlewares.AuthMiddleware, GetSuggestedUsers)
-	msg.Post("/", middlewares.AuthMiddleware, SendMessage)
+	msg.Post("/", middlewares.AuthMiddleware,
+		middlewares.RateLimit("send_message", middlewares.Limit{Count: 30, Period: time.Minute}, middlewares.Limit{Count: 120, Period: time.Minute}),
+		SendMessage)
 	msg.Get("/:otherUserId", middlewares.AuthMiddleware, GetMessages)
 	msg.Get("/search/:searchKey", middlewares.AuthMiddleware, GetSearchMatchUsers)
 	msg.Post("/conversation/:conversationID/clear/", middlewares.AuthMiddleware, ClearConversation)
@@ -32,12 +33,11 @@ type MessageData struct {
 }
 
 func SendMessage(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
 	session := database.Session.Db
 
 	user := database.User{}
 	if err := session.Model(&database.User{}).
-		Where("id = ?", userData["user_id"].(string)).
+		Where("id = ?", middlewares.AuthUserID(c)).
 		First(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -134,11 +134,10 @@ type MessageResponse struct {
 }
 
 func GetMessages(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
 	session := database.Session.Db
 	user := database.User{}
 	if err := session.Model(&database.User{}).
-		Where("id = ?", userData["user_id"].(string)).
+		Where("id = ?", middlewares.AuthUserID(c)).
 		First(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -205,9 +204,8 @@ type ConversationResponse struct {
 }
 
 func GetConversations(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
 	session := database.Session.Db
-	currentUserID := userData["user_id"].(string)
+	currentUserID := middlewares.AuthUserID(c)
 	var conversations []database.Conversation
 	if err := session.Where("participant1_id = ? OR participant2_id = ?", currentUserID, currentUserID).
 		Find(&conversations).Error; err != nil {
@@ -296,9 +294,8 @@ func GetConversations(c *fiber.Ctx) error {
 }
 
 func GetSuggestedUsers(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
 	session := database.Session.Db
-	currentUserID := userData["user_id"].(string)
+	currentUserID := middlewares.AuthUserID(c)
 
 	// Fetch users the current user is following (limit 10)
 	var following []database.Follow
@@ -423,8 +420,7 @@ func GetSuggestedUsers(c *fiber.Ctx) error {
 func GetSearchMatchUsers(c *fiber.Ctx) error {
 	searchKey := c.Params("searchKey")
 	session := database.Session.Db
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	currentUserID := userData["user_id"].(string)
+	currentUserID := middlewares.AuthUserID(c)
 
 	if searchKey == "" {
 		return c.JSON(fiber.Map{
@@ -504,8 +500,7 @@ func GetSearchMatchUsers(c *fiber.Ctx) error {
 }
 
 func ClearConversation(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	currentUserID := userData["user_id"].(string)
+	currentUserID := middlewares.AuthUserID(c)
 	conversationID := c.Params("conversationID")
 	var conversation database.Conversation
 	session := database.Session.Db
@@ -5,7 +5,6 @@ import (
 	"gradspaceBK/middlewares"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 func NotificationRoutes(base *fiber.Group) error {
@@ -19,14 +18,16 @@ func NotificationRoutes(base *fiber.Group) error {
 }
 
 func GetNotifications(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	var notifications []database.Notification
 	result := database.Session.Db.
 		Preload("Creator").
 		Preload("Post").
 		Preload("Comment").
+		Preload("Job").
+		Preload("Event").
+		Preload("Project").
 		Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Find(&notifications)
@@ -67,6 +68,32 @@ func GetNotifications(c *fiber.Ctx) error {
 			}
 		}
 
+		if notification.JobID != nil && notification.Job != nil {
+			notificationData["job"] = map[string]interface{}{
+				"id":    notification.Job.ID,
+				"title": notification.Job.Title,
+			}
+		}
+
+		if notification.EventID != nil && notification.Event != nil {
+			notificationData["event"] = map[string]interface{}{
+				"id":              notification.Event.ID,
+				"title":           notification.Event.Title,
+				"start_date_time": notification.Event.StartDateTime,
+			}
+		}
+
+		if notification.ProjectID != nil && notification.Project != nil {
+			notificationData["project"] = map[string]interface{}{
+				"id":    notification.Project.ID,
+				"title": notification.Project.Title,
+			}
+		}
+
+		if notification.Message != "" {
+			notificationData["message"] = notification.Message
+		}
+
 		response = append(response, notificationData)
 	}
 
@@ -74,8 +101,7 @@ func GetNotifications(c *fiber.Ctx) error {
 }
 
 func MarkAsRead(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	type ReadRequest struct {
 		NotificationIDs []string `json:"notificationIds"`
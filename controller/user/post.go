@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
@@ -20,7 +19,7 @@ func PostRoutes(base *fiber.Group) error {
 	post := base.Group("/posts")
 	post.Use(middlewares.AuthMiddleware)
 	
-	post.Post("/", CreatePost)
+	post.Post("/", middlewares.RateLimit("create_post", middlewares.Limit{Count: 5, Period: time.Minute}, middlewares.Limit{Count: 30, Period: time.Minute}), CreatePost)
 	post.Get("/", GetPosts)
 	post.Post("/:id/like", ToggleLike)
 	post.Post("/:id/comment", CreateComment)
@@ -32,8 +31,7 @@ func PostRoutes(base *fiber.Group) error {
 
 
 func CreatePost(c *fiber.Ctx) error {
-    userData := c.Locals("user_data").(jwt.MapClaims)
-    userID := userData["user_id"].(string)
+    userID := middlewares.AuthUserID(c)
 
     form, err := c.MultipartForm()
     if err != nil {
@@ -203,8 +201,7 @@ func getProfileImage(userID string) string {
 }
 
 func ToggleLike(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	postID := c.Params("id")
 
 	session := database.Session.Db
@@ -246,8 +243,7 @@ func ToggleLike(c *fiber.Ctx) error {
 }
 
 func CreateComment(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	postID := c.Params("id")
 
 	type CommentRequest struct {
@@ -316,8 +312,7 @@ func CreateComment(c *fiber.Ctx) error {
 }
 
 func DeletePost(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	postID := c.Params("id")
 
 	session := database.Session.Db
@@ -345,8 +340,7 @@ func DeletePost(c *fiber.Ctx) error {
 }
 
 func isPostLikedByUser(c *fiber.Ctx, postID string) bool {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 
 	var like database.Like
 	result := database.Session.Db.
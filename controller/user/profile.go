@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
@@ -34,8 +33,7 @@ func GetUserProfile(c *fiber.Ctx) error {
         return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
     }
     isFollowing := false
-    if userData, ok := c.Locals("user_data").(jwt.MapClaims); ok {
-        currentUserID := userData["user_id"].(string)
+    if currentUserID := middlewares.AuthUserID(c); currentUserID != "" {
         var follow database.Follow
         if err := session.Where("follower_id = ? AND following_id = ?", 
             currentUserID, user.ID).First(&follow).Error; err == nil {
@@ -133,6 +131,11 @@ func GetUserProfile(c *fiber.Ctx) error {
         log.Printf("Error counting following: %v", err)
     }
     
+    ownedProjects, contributedProjects, err := profileProjects(session, user.ID)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error fetching projects"})
+    }
+
     response := fiber.Map{
         "user": fiber.Map{
             "id":         user.ID,
@@ -163,13 +166,16 @@ func GetUserProfile(c *fiber.Ctx) error {
         },
         "experiences": formattedExperiences,
         "educations":  formattedEducations,
+        "projects": fiber.Map{
+            "owned":       ownedProjects,
+            "contributed": contributedProjects,
+        },
     }
     return c.JSON(response)
 }
 
 func UpdateUserProfile(c *fiber.Ctx) error {
-    userData := c.Locals("user_data").(jwt.MapClaims)
-    userID := userData["user_id"].(string)
+    userID := middlewares.AuthUserID(c)
     session := database.Session.Db
 
   
@@ -369,8 +375,7 @@ func UpdateProfileImage(c *fiber.Ctx) error {
     // Ensure multipart form is parsed even if no file is attached.
     _, _ = c.MultipartForm()
 
-    userData := c.Locals("user_data").(jwt.MapClaims)
-    userID := userData["user_id"].(string)
+    userID := middlewares.AuthUserID(c)
     session := database.Session.Db
 
     newProfileImagePath := ""
@@ -440,8 +445,7 @@ func ToggleFollow(c *fiber.Ctx) error {
     targetUserName := c.Params("userName")
     session := database.Session.Db
 
-    userData := c.Locals("user_data").(jwt.MapClaims)
-    currentUserID := userData["user_id"].(string)
+    currentUserID := middlewares.AuthUserID(c)
 
     var targetUser database.User
     if err := session.Where("user_name = ?", targetUserName).First(&targetUser).Error; err != nil {
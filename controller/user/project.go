@@ -6,11 +6,12 @@ import (
 	"gradspaceBK/database"
 	"gradspaceBK/middlewares"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 func ProjectRoutes(base *fiber.Group) {
@@ -20,6 +21,23 @@ func ProjectRoutes(base *fiber.Group) {
 	project.Post("/save", middlewares.AuthMiddleware, SaveProject)
 	project.Patch("/:id/status", middlewares.AuthMiddleware, UpdateProjectStatus)
 	project.Get("/my-projects", middlewares.AuthMiddleware, GetMyProjects)
+	project.Get("/leaderboard", middlewares.AuthMiddleware, GetProjectLeaderboard)
+	project.Get("/verifications", middlewares.AuthMiddleware, middlewares.RequireRoles("Faculty"), GetPendingProjectVerifications)
+	project.Get("/invitations", middlewares.AuthMiddleware, GetProjectInvitations)
+	project.Get("/:id/members", middlewares.AuthMiddleware, GetProjectMembers)
+	project.Post("/:id/members", middlewares.AuthMiddleware, InviteProjectMember)
+	project.Patch("/:id/members/:userId", middlewares.AuthMiddleware, UpdateProjectMemberRole)
+	project.Delete("/:id/members/:userId", middlewares.AuthMiddleware, RemoveProjectMember)
+	project.Post("/:id/invitation", middlewares.AuthMiddleware, RespondProjectInvitation)
+	project.Put("/:id/mentor", middlewares.AuthMiddleware, SetProjectMentor)
+	project.Post("/:id/verification", middlewares.AuthMiddleware, middlewares.RequireRoles("Faculty"), VerifyProject)
+	project.Post("/:id/star", middlewares.AuthMiddleware, ToggleProjectStar)
+	project.Post("/:id/endorse", middlewares.AuthMiddleware, ToggleProjectEndorsement)
+	project.Post("/:id/media", middlewares.AuthMiddleware, UploadProjectMedia)
+	project.Put("/:id/media/order", middlewares.AuthMiddleware, ReorderProjectMedia)
+	project.Patch("/:id/media/:mediaId", middlewares.AuthMiddleware, UpdateProjectMedia)
+	project.Delete("/:id/media/:mediaId", middlewares.AuthMiddleware, DeleteProjectMedia)
+	project.Put("/:id", middlewares.AuthMiddleware, UpdateProject)
 	project.Delete("/:id", middlewares.AuthMiddleware, DeleteProject)
 	project.Post("/", middlewares.AuthMiddleware, AddNewProject)
 }
@@ -29,6 +47,8 @@ type ProjectFilters struct {
 	ProjectType  database.ProjectType  `query:"project_type"`
 	Status       database.ProjectStatus `query:"status"`
 	Year         int            `query:"year"`
+	Sort         string         `query:"sort"` // recent (default), popular or trending
+	Verified     bool           `query:"verified"` // Only projects verified by a faculty mentor
 	Page         int            `query:"page"`
 	Limit        int            `query:"limit"`
 }
@@ -41,8 +61,18 @@ type ProjectResponse struct {
 	ProjectType  database.ProjectType  `json:"project_type"`
 	Year         int                   `json:"year"`
 	Mentor       string                `json:"mentor"`
-	Contributors []string              `json:"contributors"`
+	FacultyMentor      *PosterResponse `json:"faculty_mentor"` // Shown to others once the mentor has verified the project
+	VerifiedByFaculty  bool            `json:"verified_by_faculty"`
+	VerificationStatus database.ProjectVerificationStatus `json:"verification_status,omitempty"` // Only in the owner's own listing
+	VerificationNote   string          `json:"verification_note,omitempty"`
+	Contributors []string              `json:"contributors"` // Collaborators without a GradSpace account
+	Members      []ProjectMemberResponse `json:"members"`
 	Links        *database.ProjectLinks `json:"links"`
+	Repository   *ProjectRepositoryResponse `json:"repository"` // Parsed from links.code_link
+	Media        []ProjectMediaResponse `json:"media"`
+	StarCount    int64                 `json:"star_count"`
+	IsStarred    bool                  `json:"is_starred"`
+	Endorsements []ProjectEndorsementResponse `json:"endorsements"`
 	Status       database.ProjectStatus `json:"status"`
 	PostedBy     PosterResponse        `json:"posted_by"`
 	CreatedAt    string                `json:"created_at"`
@@ -50,8 +80,7 @@ type ProjectResponse struct {
 }
 
 func GetProjects(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var filters ProjectFilters
 
 	if err := c.QueryParser(&filters); err != nil {
@@ -68,8 +97,7 @@ func GetProjects(c *fiber.Ctx) error {
 	session := database.Session.Db
 
 	query := session.Model(&database.Project{}).
-		Preload("User").
-		Order("created_at DESC")
+		Preload("User")
 
 	// Apply filters
 	fmt.Println("filters.Search : ",filters.Search)
@@ -90,6 +118,17 @@ func GetProjects(c *fiber.Ctx) error {
 	if filters.Year > 0 {
 		query = query.Where("year = ?", filters.Year)
 	}
+	if filters.Verified {
+		query = query.Where("mentor_id IS NOT NULL AND verification_status = ?", database.ProjectVerificationVerified)
+	}
+
+	query, ok := applyProjectSort(query, filters.Sort)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "sort must be recent, popular or trending",
+		})
+	}
 
 	// Pagination
 	var total int64
@@ -140,6 +179,39 @@ func GetProjects(c *fiber.Ctx) error {
 		profileMap[profile.UserID] = profile
 	}
 
+	projectIDs := make([]string, 0, len(projects))
+	for _, project := range projects {
+		projectIDs = append(projectIDs, project.ID)
+	}
+	membersByProject, err := loadProjectMembers(session, projectIDs, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project members",
+		})
+	}
+	mediaByProject, err := loadProjectMedia(session, projectIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project media",
+		})
+	}
+	appreciation, err := loadProjectAppreciation(session, projectIDs, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project stars",
+		})
+	}
+	mentors, err := loadProjectMentors(session, projects, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project mentors",
+		})
+	}
+
 	transformedProjects := make([]ProjectResponse, 0, len(projects))
 	for _, project := range projects {
 		// Get user info
@@ -181,8 +253,16 @@ func GetProjects(c *fiber.Ctx) error {
 				ProjectType:  project.ProjectType,
 				Year:         project.Year,
 				Mentor:       project.Mentor,
+				FacultyMentor:     mentors[project.ID],
+				VerifiedByFaculty: isFacultyVerified(project),
 				Contributors: contributors,
+				Members:      append(make([]ProjectMemberResponse, 0), membersByProject[project.ID]...),
 				Links:        &links,
+				Repository:   projectRepository(project),
+				Media:        append(make([]ProjectMediaResponse, 0), mediaByProject[project.ID]...),
+				StarCount:    appreciation[project.ID].StarCount,
+				IsStarred:    appreciation[project.ID].IsStarred,
+				Endorsements: append(make([]ProjectEndorsementResponse, 0), appreciation[project.ID].Endorsements...),
 				Status:       project.Status,
 				PostedBy:     poster,
 				CreatedAt:    project.CreatedAt.Format("2006-01-02"),
@@ -205,8 +285,7 @@ func GetProjects(c *fiber.Ctx) error {
 }
 
 func GetSavedProjects(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var pagination struct {
 		Page  int `query:"page"`
 		Limit int `query:"limit"`
@@ -263,6 +342,43 @@ func GetSavedProjects(c *fiber.Ctx) error {
 		profileMap[profile.UserID] = profile
 	}
 
+	projectIDs := make([]string, 0, len(savedProjects))
+	for _, saved := range savedProjects {
+		projectIDs = append(projectIDs, saved.ProjectID)
+	}
+	membersByProject, err := loadProjectMembers(database.Session.Db, projectIDs, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project members",
+		})
+	}
+	mediaByProject, err := loadProjectMedia(database.Session.Db, projectIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project media",
+		})
+	}
+	appreciation, err := loadProjectAppreciation(database.Session.Db, projectIDs, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project stars",
+		})
+	}
+	savedProjectList := make([]database.Project, 0, len(savedProjects))
+	for _, saved := range savedProjects {
+		savedProjectList = append(savedProjectList, saved.Project)
+	}
+	mentors, err := loadProjectMentors(database.Session.Db, savedProjectList, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project mentors",
+		})
+	}
+
 	transformedProjects := make([]ProjectResponse, 0, len(savedProjects))
 	for _, saved := range savedProjects {
 		project := saved.Project
@@ -301,8 +417,16 @@ func GetSavedProjects(c *fiber.Ctx) error {
 			ProjectType:  project.ProjectType,
 			Year:         project.Year,
 			Mentor:       project.Mentor,
+			FacultyMentor:     mentors[project.ID],
+			VerifiedByFaculty: isFacultyVerified(project),
 			Contributors: contributors,
+			Members:      append(make([]ProjectMemberResponse, 0), membersByProject[project.ID]...),
 			Links:        &links,
+			Repository:   projectRepository(project),
+			Media:        append(make([]ProjectMediaResponse, 0), mediaByProject[project.ID]...),
+			StarCount:    appreciation[project.ID].StarCount,
+			IsStarred:    appreciation[project.ID].IsStarred,
+			Endorsements: append(make([]ProjectEndorsementResponse, 0), appreciation[project.ID].Endorsements...),
 			Status:       project.Status,
 			PostedBy:     poster,
 			CreatedAt:    project.CreatedAt.Format("2006-01-02"),
@@ -324,8 +448,7 @@ func GetSavedProjects(c *fiber.Ctx) error {
 }
 
 func SaveProject(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var req struct {
 		ProjectID string `json:"project_id"`
 	}
@@ -375,8 +498,7 @@ func SaveProject(c *fiber.Ctx) error {
 }
 
 func GetMyProjects(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	var pagination struct {
 		Page  int `query:"page"`
 		Limit int `query:"limit"`
@@ -415,6 +537,40 @@ func GetMyProjects(c *fiber.Ctx) error {
 		First(&userProfile).Error; err != nil {
 		userProfile = database.UserProfile{}
 	}
+	projectIDs := make([]string, 0, len(projects))
+	for _, project := range projects {
+		projectIDs = append(projectIDs, project.ID)
+	}
+	// The owner also sees open invitations on their own projects
+	membersByProject, err := loadProjectMembers(database.Session.Db, projectIDs, true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project members",
+		})
+	}
+	mediaByProject, err := loadProjectMedia(database.Session.Db, projectIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project media",
+		})
+	}
+	appreciation, err := loadProjectAppreciation(database.Session.Db, projectIDs, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project stars",
+		})
+	}
+	mentors, err := loadProjectMentors(database.Session.Db, projects, true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project mentors",
+		})
+	}
+
 	transformedProjects := make([]ProjectResponse, 0, len(projects))
 	for _, project := range projects {
 		// Get user info
@@ -449,8 +605,18 @@ func GetMyProjects(c *fiber.Ctx) error {
 				ProjectType:  project.ProjectType,
 				Year:         project.Year,
 				Mentor:       project.Mentor,
+				FacultyMentor:     mentors[project.ID],
+				VerifiedByFaculty: isFacultyVerified(project),
+				VerificationStatus: project.VerificationStatus,
+				VerificationNote:   project.VerificationNote,
 				Contributors: contributors,
+				Members:      append(make([]ProjectMemberResponse, 0), membersByProject[project.ID]...),
 				Links:        &links,
+				Repository:   projectRepository(project),
+				Media:        append(make([]ProjectMediaResponse, 0), mediaByProject[project.ID]...),
+				StarCount:    appreciation[project.ID].StarCount,
+				IsStarred:    appreciation[project.ID].IsStarred,
+				Endorsements: append(make([]ProjectEndorsementResponse, 0), appreciation[project.ID].Endorsements...),
 				Status:       project.Status,
 				PostedBy:     poster,
 				CreatedAt:    project.CreatedAt.Format("2006-01-02"),
@@ -472,10 +638,14 @@ func GetMyProjects(c *fiber.Ctx) error {
 }
 
 func DeleteProject(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	projectID := c.Params("id")
 
+	var mediaPaths []string
+	database.Session.Db.Model(&database.ProjectMedia{}).
+		Where("project_id = ?", projectID).
+		Pluck("path", &mediaPaths)
+
 	result := database.Session.Db.
 		Where("id = ? AND posted_by = ?", projectID, userID).
 		Delete(&database.Project{})
@@ -486,28 +656,19 @@ func DeleteProject(c *fiber.Ctx) error {
 			"message": "Not authorized or project not found",
 		})
 	}
+	// Gallery rows go with the project; their files have to be removed here
+	for _, path := range mediaPaths {
+		os.Remove(path)
+	}
 	return c.JSON(fiber.Map{"success": true})
 }
 
 func AddNewProject(c *fiber.Ctx) error {
-    userData := c.Locals("user_data").(jwt.MapClaims)
-    userID, _ := userData["user_id"].(string)
+    userID := middlewares.AuthUserID(c)
     
     var req struct {
-        Title        string   `json:"title"`
-        Description  string   `json:"description"`
-        Tags         []string `json:"tags"`
-        ProjectType  database.ProjectType `json:"project_type"`
-        Year         int      `json:"year"`
-        Mentor       string   `json:"mentor"`
-        Contributors []string `json:"contributors"`
-        Links        struct {
-            CodeLink string `json:"code_link"`
-            Video    string `json:"video"`
-            Files    string `json:"files"`
-            Website  string `json:"website"`
-        } `json:"links"`
-		Status database.ProjectStatus `json:"status"`
+        ProjectRequest
+        Members []ProjectMemberRequest `json:"members"` // GradSpace users to invite
     }
 
     if err := c.BodyParser(&req); err != nil {
@@ -517,32 +678,37 @@ func AddNewProject(c *fiber.Ctx) error {
             "message": "Invalid request",
         })
     }
-
-    // Marshal JSON data
-    tagsBytes, _ := json.Marshal(req.Tags)
-    contributorsBytes, _ := json.Marshal(req.Contributors)
-    linksBytes, _ := json.Marshal(req.Links)
-
-    projectData := database.Project{
-        Title:        req.Title,
-        Description:  req.Description,
-        Tags:         tagsBytes,
-        ProjectType:  req.ProjectType,
-        Year:         req.Year,
-        Mentor:       req.Mentor,
-        Contributors: contributorsBytes,
-        Links:        linksBytes,
-        Status:       req.Status,
-        PostedBy:     userID,
+    if req.Year == 0 {
+        req.Year = time.Now().Year()
     }
-
-    if err := database.Session.Db.Create(&projectData).Error; err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+    if req.Status == "" {
+        req.Status = database.ProjectStatusActive
+    }
+    if errors := validateProjectRequest(req.ProjectRequest); len(errors) > 0 {
+        return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
             "success": false,
-            "message": "Failed to create project",
+            "errors":  errors,
         })
     }
 
+    projectData := database.Project{PostedBy: userID}
+    applyProjectRequest(&projectData, req.ProjectRequest)
+
+    err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(&projectData).Error; err != nil {
+            return err
+        }
+        for _, member := range req.Members {
+            if err := inviteProjectMember(tx, projectData, userID, member); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return projectErrorResponse(c, err, "Failed to create project")
+    }
+
     return c.Status(fiber.StatusCreated).JSON(fiber.Map{
         "success": true,
         "data": fiber.Map{
@@ -555,8 +721,7 @@ func AddNewProject(c *fiber.Ctx) error {
 }
 
 func UpdateProjectStatus(c *fiber.Ctx) error {
-	userData := c.Locals("user_data").(jwt.MapClaims)
-	userID, _ := userData["user_id"].(string)
+	userID := middlewares.AuthUserID(c)
 	projectID := c.Params("id")
 	var req struct {
 		Status database.ProjectStatus `json:"status" validate:"required,oneof=ACTIVE COMPLETED"`
@@ -588,46 +753,90 @@ func UpdateProjectStatus(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true, "status": project.Status})
 }
 
-func validateProjectRequest(req struct {
-	Title        string                `json:"title" validate:"required,min=5,max=255"`
-	Description  string                `json:"description" validate:"required,min=10"`
-	Tags         []string              `json:"tags" validate:"required"`
-	ProjectType  database.ProjectType  `json:"project_type" validate:"required,oneof=PERSONAL GROUP COLLEGE"`
-	Year         int                   `json:"year" validate:"required"`
-	Mentor       string                `json:"mentor"`
-	Contributors []string              `json:"contributors" validate:"required"`
-	Links        *database.ProjectLinks `json:"links"`
-}) map[string]string {
+type ProjectRequest struct {
+	Title        string                 `json:"title" validate:"required,min=5,max=255"`
+	Description  string                 `json:"description" validate:"required,min=10"`
+	Tags         []string               `json:"tags" validate:"required"`
+	ProjectType  database.ProjectType   `json:"project_type" validate:"required,oneof=PERSONAL GROUP COLLEGE"`
+	Year         int                    `json:"year" validate:"required"`
+	Mentor       string                 `json:"mentor"`
+	// Free-text names of collaborators without a GradSpace account
+	Contributors []string               `json:"contributors"`
+	Links        database.ProjectLinks  `json:"links"`
+	Status       database.ProjectStatus `json:"status" validate:"required,oneof=ACTIVE COMPLETED"`
+}
+
+func validateProjectRequest(req ProjectRequest) map[string]string {
 	errors := make(map[string]string)
 	
 	if strings.TrimSpace(req.Title) == "" {
 		errors["title"] = "Title is required"
+	} else if len(req.Title) > 255 {
+		errors["title"] = "Title must be at most 255 characters"
+	}
+
+	if strings.TrimSpace(req.Description) == "" {
+		errors["description"] = "Description is required"
 	}
 	
-	if req.ProjectType == "" {
-		errors["project_type"] = "Project type is required"
+	if req.ProjectType != database.ProjectTypePersonal && req.ProjectType != database.ProjectTypeGroup &&
+		req.ProjectType != database.ProjectTypeCollege {
+		errors["project_type"] = "Project type must be PERSONAL, GROUP or COLLEGE"
+	}
+
+	if req.Status != database.ProjectStatusActive && req.Status != database.ProjectStatusCompleted {
+		errors["status"] = "Status must be ACTIVE or COMPLETED"
 	}
 	
 	if req.Year < 2000 || req.Year > time.Now().Year()+5 {
 		errors["year"] = "Invalid year value"
 	}
 	
-	if len(req.Contributors) == 0 {
-		errors["contributors"] = "At least one contributor is required"
-	}
-	
-	if req.Links != nil {
-		if req.Links.Website != "" {
-			if _, err := url.ParseRequestURI(req.Links.Website); err != nil {
-				errors["links.website"] = "Invalid website URL"
-			}
+	links := map[string]string{
+		"links.code_link": req.Links.CodeLink,
+		"links.video":     req.Links.Video,
+		"links.files":     req.Links.Files,
+		"links.website":   req.Links.Website,
+	}
+	for field, link := range links {
+		if link == "" {
+			continue
 		}
-		if req.Links.CodeLink != "" {
-			if _, err := url.ParseRequestURI(req.Links.CodeLink); err != nil {
-				errors["links.code_link"] = "Invalid code link URL"
-			}
+		if u, err := url.ParseRequestURI(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors[field] = "Invalid URL"
+		}
+	}
+	if _, ok := errors["links.code_link"]; !ok && req.Links.CodeLink != "" {
+		if _, _, err := database.ParseRepositoryURL(req.Links.CodeLink); err != nil {
+			errors["links.code_link"] = err.Error()
 		}
 	}
 
 	return errors
-}
\ No newline at end of file
+}
+
+// applyProjectRequest copies the editable fields of req onto project.
+func applyProjectRequest(project *database.Project, req ProjectRequest) {
+	tags := make([]string, 0, len(req.Tags))
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	contributors := make([]string, 0, len(req.Contributors))
+	for _, name := range req.Contributors {
+		if name = strings.TrimSpace(name); name != "" {
+			contributors = append(contributors, name)
+		}
+	}
+
+	project.Title = strings.TrimSpace(req.Title)
+	project.Description = req.Description
+	project.Tags, _ = json.Marshal(tags)
+	project.ProjectType = req.ProjectType
+	project.Year = req.Year
+	project.Mentor = strings.TrimSpace(req.Mentor)
+	project.Contributors, _ = json.Marshal(contributors)
+	project.Links, _ = json.Marshal(req.Links)
+	project.Status = req.Status
+}
@@ -0,0 +1,346 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	projectMediaDir   = "./uploads/project-media"
+	maxProjectMedia   = 12
+	maxProjectCaption = 255
+)
+
+// Accepted gallery formats, by sniffed content type
+var projectMediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type ProjectMediaResponse struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	Caption  string `json:"caption"`
+	Position int    `json:"position"`
+}
+
+// loadProjectMedia returns each project's gallery in display order, keyed by
+// project ID.
+func loadProjectMedia(session *gorm.DB, projectIDs []string) (map[string][]ProjectMediaResponse, error) {
+	mediaByProject := make(map[string][]ProjectMediaResponse)
+	if len(projectIDs) == 0 {
+		return mediaByProject, nil
+	}
+
+	var media []database.ProjectMedia
+	if err := session.Where("project_id IN ?", projectIDs).
+		Order("position, created_at").
+		Find(&media).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range media {
+		mediaByProject[item.ProjectID] = append(mediaByProject[item.ProjectID], ProjectMediaResponse{
+			ID:       item.ID,
+			URL:      item.Path,
+			Caption:  item.Caption,
+			Position: item.Position,
+		})
+	}
+	return mediaByProject, nil
+}
+
+// saveProjectMediaFile stores an uploaded image under the uploads directory
+// after checking its content, not just its extension.
+func saveProjectMediaFile(c *fiber.Ctx, file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, 512)
+	n, _ := src.Read(header)
+	src.Close()
+
+	ext, ok := projectMediaTypes[http.DetectContentType(header[:n])]
+	if !ok {
+		return "", fiber.NewError(fiber.StatusUnprocessableEntity,
+			fmt.Sprintf("%s is not a JPEG, PNG, GIF or WebP image", file.Filename))
+	}
+
+	if err := os.MkdirAll(projectMediaDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	newFileName := fmt.Sprintf("%d-%s%s", time.Now().UnixNano(), uuid.New().String(), ext)
+	savePath := filepath.Join(projectMediaDir, newFileName)
+	if err := c.SaveFile(file, savePath); err != nil {
+		return "", err
+	}
+	return savePath, nil
+}
+
+// UploadProjectMedia adds one or more images to a project's gallery. Captions
+// are matched to files by position.
+func UploadProjectMedia(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["media"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "At least one media file is required",
+		})
+	}
+	files := form.File["media"]
+	captions := form.Value["caption"]
+
+	session := database.Session.Db
+	project, canManage, err := projectAccess(session, c.Params("id"), userID)
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to upload media")
+	}
+	if !canManage {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Not authorized to update this project",
+		})
+	}
+
+	var saved []string
+	removeSaved := func() {
+		for _, path := range saved {
+			os.Remove(path)
+		}
+	}
+	for _, file := range files {
+		path, err := saveProjectMediaFile(c, file)
+		if err != nil {
+			removeSaved()
+			return projectErrorResponse(c, err, "Failed to save media file")
+		}
+		saved = append(saved, path)
+	}
+
+	var created []database.ProjectMedia
+	err = session.Transaction(func(tx *gorm.DB) error {
+		// Serialize uploads per project so the limit holds
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&database.Project{}, "id = ?", project.ID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		var lastPosition *int
+		if err := tx.Model(&database.ProjectMedia{}).Where("project_id = ?", project.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count+int64(len(saved)) > maxProjectMedia {
+			return fiber.NewError(fiber.StatusUnprocessableEntity,
+				fmt.Sprintf("A project can have at most %d media items", maxProjectMedia))
+		}
+		if err := tx.Model(&database.ProjectMedia{}).Where("project_id = ?", project.ID).
+			Select("MAX(position)").Scan(&lastPosition).Error; err != nil {
+			return err
+		}
+		position := 0
+		if lastPosition != nil {
+			position = *lastPosition + 1
+		}
+
+		for i, path := range saved {
+			caption := ""
+			if i < len(captions) {
+				caption = strings.TrimSpace(captions[i])
+			}
+			if len(caption) > maxProjectCaption {
+				return fiber.NewError(fiber.StatusUnprocessableEntity,
+					fmt.Sprintf("Captions must be at most %d characters", maxProjectCaption))
+			}
+			created = append(created, database.ProjectMedia{
+				ProjectID:  project.ID,
+				Path:       path,
+				Caption:    caption,
+				Position:   position + i,
+				UploadedBy: userID,
+			})
+		}
+		return tx.Create(&created).Error
+	})
+	if err != nil {
+		removeSaved()
+		return projectErrorResponse(c, err, "Failed to upload media")
+	}
+
+	response := make([]ProjectMediaResponse, 0, len(created))
+	for _, item := range created {
+		response = append(response, ProjectMediaResponse{
+			ID:       item.ID,
+			URL:      item.Path,
+			Caption:  item.Caption,
+			Position: item.Position,
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    fiber.Map{"media": response},
+	})
+}
+
+// UpdateProjectMedia changes the caption of a gallery item.
+func UpdateProjectMedia(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req struct {
+		Caption string `json:"caption"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	req.Caption = strings.TrimSpace(req.Caption)
+	if len(req.Caption) > maxProjectCaption {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  fiber.Map{"caption": fmt.Sprintf("Caption must be at most %d characters", maxProjectCaption)},
+		})
+	}
+
+	session := database.Session.Db
+	project, canManage, err := projectAccess(session, c.Params("id"), userID)
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to update media")
+	}
+	if !canManage {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Not authorized to update this project",
+		})
+	}
+
+	result := session.Model(&database.ProjectMedia{}).
+		Where("id = ? AND project_id = ?", c.Params("mediaId"), project.ID).
+		Update("caption", req.Caption)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update media",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Media not found",
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "caption": req.Caption})
+}
+
+// ReorderProjectMedia sets the gallery order. The request must list every
+// item of the gallery exactly once.
+func ReorderProjectMedia(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req struct {
+		MediaIDs []string `json:"media_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		project, canManage, err := projectAccess(tx, c.Params("id"), userID)
+		if err != nil {
+			return err
+		}
+		if !canManage {
+			return fiber.NewError(fiber.StatusForbidden, "Not authorized to update this project")
+		}
+
+		var existing []string
+		if err := tx.Model(&database.ProjectMedia{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("project_id = ?", project.ID).
+			Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		remaining := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			remaining[id] = true
+		}
+		for _, id := range req.MediaIDs {
+			if !remaining[id] {
+				return fiber.NewError(fiber.StatusUnprocessableEntity, "media_ids must list every media item of the project once")
+			}
+			delete(remaining, id)
+		}
+		if len(remaining) > 0 {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "media_ids must list every media item of the project once")
+		}
+
+		for position, id := range req.MediaIDs {
+			if err := tx.Model(&database.ProjectMedia{}).Where("id = ?", id).
+				Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to reorder media")
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// DeleteProjectMedia removes an item from the gallery and its file from disk.
+func DeleteProjectMedia(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	session := database.Session.Db
+	project, canManage, err := projectAccess(session, c.Params("id"), userID)
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to delete media")
+	}
+	if !canManage {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Not authorized to update this project",
+		})
+	}
+
+	var media database.ProjectMedia
+	if err := session.Where("id = ? AND project_id = ?", c.Params("mediaId"), project.ID).
+		First(&media).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "Media not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete media",
+		})
+	}
+	if err := session.Delete(&media).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete media",
+		})
+	}
+	os.Remove(media.Path)
+
+	return c.JSON(fiber.Map{"success": true})
+}
@@ -0,0 +1,494 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var projectMemberRoles = map[database.ProjectMemberRole]string{
+	database.ProjectRoleMaintainer:  "maintainer",
+	database.ProjectRoleDeveloper:   "developer",
+	database.ProjectRoleDesigner:    "designer",
+	database.ProjectRoleResearcher:  "researcher",
+	database.ProjectRoleContributor: "contributor",
+}
+
+const projectRoleError = "Role must be MAINTAINER, DEVELOPER, DESIGNER, RESEARCHER or CONTRIBUTOR"
+
+type ProjectMemberRequest struct {
+	UserID string                     `json:"user_id"`
+	Role   database.ProjectMemberRole `json:"role"`
+}
+
+type ProjectMemberResponse struct {
+	PosterResponse
+	Role   database.ProjectMemberRole   `json:"role"`
+	Status database.ProjectMemberStatus `json:"status,omitempty"` // Only shown to the owner and maintainers
+}
+
+// projectAccess loads a project and reports whether userID may manage its
+// members: the owner and accepted maintainers can.
+func projectAccess(session *gorm.DB, projectID, userID string) (database.Project, bool, error) {
+	var project database.Project
+	if err := session.First(&project, "id = ?", projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return project, false, fiber.NewError(fiber.StatusNotFound, "Project not found")
+		}
+		return project, false, err
+	}
+	if project.PostedBy == userID {
+		return project, true, nil
+	}
+	var maintainers int64
+	err := session.Model(&database.ProjectMember{}).
+		Where("project_id = ? AND user_id = ? AND status = ? AND role = ?",
+			projectID, userID, database.ProjectMemberAccepted, database.ProjectRoleMaintainer).
+		Count(&maintainers).Error
+	return project, maintainers > 0, err
+}
+
+// inviteProjectMember invites a user to the project, or re-invites one who
+// declined earlier, and notifies them.
+func inviteProjectMember(tx *gorm.DB, project database.Project, inviterID string, req ProjectMemberRequest) error {
+	if req.Role == "" {
+		req.Role = database.ProjectRoleContributor
+	}
+	if _, ok := projectMemberRoles[req.Role]; !ok {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, projectRoleError)
+	}
+	if req.Role == database.ProjectRoleMaintainer && inviterID != project.PostedBy {
+		return fiber.NewError(fiber.StatusForbidden, "Only the project owner can add maintainers")
+	}
+	if req.UserID == project.PostedBy {
+		return fiber.NewError(fiber.StatusConflict, "The project owner is already a member")
+	}
+
+	var invitee database.User
+	if err := tx.Select("id").First(&invitee, "id = ?", req.UserID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	var member database.ProjectMember
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("project_id = ? AND user_id = ?", project.ID, req.UserID).
+		First(&member).Error
+	switch {
+	case err == nil && member.Status != database.ProjectMemberDeclined:
+		return fiber.NewError(fiber.StatusConflict, "User is already a member or has a pending invitation")
+	case err == nil:
+		if err := tx.Model(&member).Updates(map[string]interface{}{
+			"role":         req.Role,
+			"status":       database.ProjectMemberInvited,
+			"invited_by":   inviterID,
+			"responded_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		member = database.ProjectMember{
+			ProjectID: project.ID,
+			UserID:    req.UserID,
+			Role:      req.Role,
+			Status:    database.ProjectMemberInvited,
+			InvitedBy: inviterID,
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	notification := database.Notification{
+		UserID:    req.UserID,
+		CreatorID: inviterID,
+		Type:      database.NotificationTypeProjectInvite,
+		ProjectID: &project.ID,
+		Message:   fmt.Sprintf("You were invited to join \"%s\" as a %s.", project.Title, projectMemberRoles[req.Role]),
+	}
+	return tx.Create(&notification).Error
+}
+
+// loadProjectMembers returns the accepted members of each project, keyed by
+// project ID. With withPending set, open and declined invitations are
+// included as well.
+func loadProjectMembers(session *gorm.DB, projectIDs []string, withPending bool) (map[string][]ProjectMemberResponse, error) {
+	membersByProject := make(map[string][]ProjectMemberResponse)
+	if len(projectIDs) == 0 {
+		return membersByProject, nil
+	}
+
+	query := session.Preload("User").Where("project_id IN ?", projectIDs)
+	if !withPending {
+		query = query.Where("status = ?", database.ProjectMemberAccepted)
+	}
+	var members []database.ProjectMember
+	if err := query.Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	profileMap := make(map[string]database.UserProfile)
+	if len(userIDs) > 0 {
+		var profiles []database.UserProfile
+		if err := session.Where("user_id IN ?", userIDs).Find(&profiles).Error; err != nil {
+			return nil, err
+		}
+		for _, profile := range profiles {
+			profileMap[profile.UserID] = profile
+		}
+	}
+
+	for _, member := range members {
+		userName := ""
+		if member.User.UserName != nil {
+			userName = *member.User.UserName
+		}
+		response := ProjectMemberResponse{
+			PosterResponse: PosterResponse{
+				ID:           member.User.ID,
+				FullName:     member.User.FullName,
+				UserName:     userName,
+				ProfileImage: profileMap[member.UserID].ProfileImage,
+			},
+			Role: member.Role,
+		}
+		if withPending {
+			response.Status = member.Status
+		}
+		membersByProject[member.ProjectID] = append(membersByProject[member.ProjectID], response)
+	}
+	return membersByProject, nil
+}
+
+// GetProjectMembers lists a project's members. The owner and maintainers also
+// see pending and declined invitations.
+func GetProjectMembers(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	session := database.Session.Db
+	project, canManage, err := projectAccess(session, c.Params("id"), userID)
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to fetch project members")
+	}
+
+	members, err := loadProjectMembers(session, []string{project.ID}, canManage)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project members",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"members": append(make([]ProjectMemberResponse, 0), members[project.ID]...),
+		},
+	})
+}
+
+// InviteProjectMember invites a GradSpace user to contribute to a project.
+func InviteProjectMember(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req ProjectMemberRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.UserID) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		project, canManage, err := projectAccess(tx, c.Params("id"), userID)
+		if err != nil {
+			return err
+		}
+		if !canManage {
+			return fiber.NewError(fiber.StatusForbidden, "Not authorized to invite members to this project")
+		}
+		return inviteProjectMember(tx, project, userID, req)
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to send invitation")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Invitation sent",
+	})
+}
+
+// GetProjectInvitations lists the current user's open project invitations.
+func GetProjectInvitations(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var invitations []database.ProjectMember
+	if err := database.Session.Db.
+		Preload("Project").
+		Preload("Inviter").
+		Where("user_id = ? AND status = ?", userID, database.ProjectMemberInvited).
+		Order("updated_at DESC").
+		Find(&invitations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch invitations",
+		})
+	}
+
+	response := make([]fiber.Map, 0, len(invitations))
+	for _, invitation := range invitations {
+		inviterName := ""
+		if invitation.Inviter.UserName != nil {
+			inviterName = *invitation.Inviter.UserName
+		}
+		response = append(response, fiber.Map{
+			"project": fiber.Map{
+				"id":           invitation.Project.ID,
+				"title":        invitation.Project.Title,
+				"project_type": invitation.Project.ProjectType,
+				"year":         invitation.Project.Year,
+			},
+			"role": invitation.Role,
+			"invited_by": fiber.Map{
+				"id":        invitation.Inviter.ID,
+				"full_name": invitation.Inviter.FullName,
+				"username":  inviterName,
+			},
+			"invited_at": invitation.UpdatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    fiber.Map{"invitations": response},
+	})
+}
+
+// RespondProjectInvitation accepts or declines the current user's invitation
+// to a project. Accepting notifies whoever sent the invitation.
+func RespondProjectInvitation(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	projectID := c.Params("id")
+
+	var req struct {
+		Decision string `json:"decision"` // "accept" or "decline"
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+
+	var status database.ProjectMemberStatus
+	switch req.Decision {
+	case "accept":
+		status = database.ProjectMemberAccepted
+	case "decline":
+		status = database.ProjectMemberDeclined
+	default:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  fiber.Map{"decision": "Decision must be accept or decline"},
+		})
+	}
+
+	var member database.ProjectMember
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Project").
+			Where("project_id = ? AND user_id = ? AND status = ?", projectID, userID, database.ProjectMemberInvited).
+			First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "Invitation not found")
+			}
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&member).Updates(map[string]interface{}{
+			"status":       status,
+			"responded_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		member.Status = status
+
+		if status != database.ProjectMemberAccepted {
+			return nil
+		}
+		notification := database.Notification{
+			UserID:    member.InvitedBy,
+			CreatorID: userID,
+			Type:      database.NotificationTypeProjectAccepted,
+			ProjectID: &member.ProjectID,
+			Message:   fmt.Sprintf("Your invitation to join \"%s\" was accepted.", member.Project.Title),
+		}
+		return tx.Create(&notification).Error
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to respond to invitation")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"project_id": member.ProjectID,
+			"role":       member.Role,
+			"status":     member.Status,
+		},
+	})
+}
+
+// UpdateProjectMemberRole changes a member's role. Only the owner can do this.
+func UpdateProjectMemberRole(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req struct {
+		Role database.ProjectMemberRole `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	if _, ok := projectMemberRoles[req.Role]; !ok {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  fiber.Map{"role": projectRoleError},
+		})
+	}
+
+	session := database.Session.Db
+	project, _, err := projectAccess(session, c.Params("id"), userID)
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to update member role")
+	}
+	if project.PostedBy != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Only the project owner can change member roles",
+		})
+	}
+
+	result := session.Model(&database.ProjectMember{}).
+		Where("project_id = ? AND user_id = ? AND status <> ?", project.ID, c.Params("userId"), database.ProjectMemberDeclined).
+		Update("role", req.Role)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update member role",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Member not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "role": req.Role})
+}
+
+// RemoveProjectMember removes a member or withdraws an invitation. Members can
+// also remove themselves to leave a project; maintainers can remove anyone
+// except other maintainers.
+func RemoveProjectMember(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+	memberID := c.Params("userId")
+
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		project, canManage, err := projectAccess(tx, c.Params("id"), userID)
+		if err != nil {
+			return err
+		}
+
+		var member database.ProjectMember
+		if err := tx.Where("project_id = ? AND user_id = ?", project.ID, memberID).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "Member not found")
+			}
+			return err
+		}
+
+		leaving := memberID == userID
+		ownerAction := project.PostedBy == userID
+		if !leaving && !ownerAction && (!canManage || member.Role == database.ProjectRoleMaintainer) {
+			return fiber.NewError(fiber.StatusForbidden, "Not authorized to remove this member")
+		}
+		return tx.Delete(&member).Error
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to remove member")
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// profileProjects returns summaries of the projects a user owns and those they
+// contribute to as an accepted member, newest first.
+func profileProjects(session *gorm.DB, userID string) ([]fiber.Map, []fiber.Map, error) {
+	var owned []database.Project
+	if err := session.Where("posted_by = ?", userID).
+		Order("created_at DESC").
+		Find(&owned).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var memberships []database.ProjectMember
+	if err := session.Preload("Project").
+		Joins("JOIN projects ON projects.id = project_members.project_id").
+		Where("project_members.user_id = ? AND project_members.status = ?", userID, database.ProjectMemberAccepted).
+		Order("projects.created_at DESC").
+		Find(&memberships).Error; err != nil {
+		return nil, nil, err
+	}
+
+	summary := func(project database.Project) fiber.Map {
+		var tags []string
+		json.Unmarshal(project.Tags, &tags)
+		return fiber.Map{
+			"id":                project.ID,
+			"title":             project.Title,
+			"description":       project.Description,
+			"tags":              tags,
+			"projectType":       project.ProjectType,
+			"year":              project.Year,
+			"status":            project.Status,
+			"verifiedByFaculty": isFacultyVerified(project),
+		}
+	}
+
+	ownedProjects := make([]fiber.Map, 0, len(owned))
+	for _, project := range owned {
+		ownedProjects = append(ownedProjects, summary(project))
+	}
+	contributedProjects := make([]fiber.Map, 0, len(memberships))
+	for _, membership := range memberships {
+		project := summary(membership.Project)
+		project["role"] = membership.Role
+		contributedProjects = append(contributedProjects, project)
+	}
+	return ownedProjects, contributedProjects, nil
+}
+
+func projectErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": fiberErr.Message,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": fallback,
+	})
+}
@@ -0,0 +1,357 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Stars and endorsements newer than this count towards sort=trending.
+const projectTrendingWindow = 7 * 24 * time.Hour
+
+const (
+	projectStarCountSQL        = "(SELECT COUNT(*) FROM project_stars WHERE project_stars.project_id = projects.id)"
+	projectEndorsementCountSQL = "(SELECT COUNT(*) FROM project_endorsements WHERE project_endorsements.project_id = projects.id)"
+	projectScoreSQL            = "(" + projectStarCountSQL + " + " + projectEndorsementCountSQL + ")"
+)
+
+type ProjectEndorsementResponse struct {
+	Skill    string `json:"skill"`
+	Count    int64  `json:"count"`
+	Endorsed bool   `json:"endorsed"` // Whether the current user endorsed this skill
+}
+
+type projectAppreciation struct {
+	StarCount    int64
+	IsStarred    bool
+	Endorsements []ProjectEndorsementResponse
+}
+
+// loadProjectAppreciation counts stars and endorsements for each project,
+// keyed by project ID. Endorsements are ordered by count, highest first.
+func loadProjectAppreciation(session *gorm.DB, projectIDs []string, userID string) (map[string]projectAppreciation, error) {
+	appreciation := make(map[string]projectAppreciation)
+	if len(projectIDs) == 0 {
+		return appreciation, nil
+	}
+
+	var stars []struct {
+		ProjectID string
+		Total     int64
+		Mine      int64
+	}
+	if err := session.Model(&database.ProjectStar{}).
+		Select("project_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE user_id = ?) AS mine", userID).
+		Where("project_id IN ?", projectIDs).
+		Group("project_id").
+		Scan(&stars).Error; err != nil {
+		return nil, err
+	}
+	for _, star := range stars {
+		entry := appreciation[star.ProjectID]
+		entry.StarCount = star.Total
+		entry.IsStarred = star.Mine > 0
+		appreciation[star.ProjectID] = entry
+	}
+
+	var endorsements []struct {
+		ProjectID string
+		Skill     string
+		Total     int64
+		Mine      int64
+	}
+	if err := session.Model(&database.ProjectEndorsement{}).
+		Select("project_id, skill, COUNT(*) AS total, COUNT(*) FILTER (WHERE user_id = ?) AS mine", userID).
+		Where("project_id IN ?", projectIDs).
+		Group("project_id, skill").
+		Order("total DESC, skill").
+		Scan(&endorsements).Error; err != nil {
+		return nil, err
+	}
+	for _, endorsement := range endorsements {
+		entry := appreciation[endorsement.ProjectID]
+		entry.Endorsements = append(entry.Endorsements, ProjectEndorsementResponse{
+			Skill:    endorsement.Skill,
+			Count:    endorsement.Total,
+			Endorsed: endorsement.Mine > 0,
+		})
+		appreciation[endorsement.ProjectID] = entry
+	}
+	return appreciation, nil
+}
+
+// applyProjectSort orders a projects query. recent is the default.
+func applyProjectSort(query *gorm.DB, sortBy string) (*gorm.DB, bool) {
+	switch sortBy {
+	case "", "recent":
+		return query.Order("created_at DESC"), true
+	case "popular":
+		return query.Order(projectScoreSQL + " DESC").Order("created_at DESC"), true
+	case "trending":
+		since := time.Now().Add(-projectTrendingWindow)
+		return query.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL: "((SELECT COUNT(*) FROM project_stars WHERE project_stars.project_id = projects.id AND project_stars.created_at >= ?) + " +
+				"(SELECT COUNT(*) FROM project_endorsements WHERE project_endorsements.project_id = projects.id AND project_endorsements.created_at >= ?)) DESC",
+			Vars: []interface{}{since, since},
+		}}).Order(projectScoreSQL + " DESC").Order("created_at DESC"), true
+	default:
+		return query, false
+	}
+}
+
+// loadAppreciableProject loads a project the user may star or endorse. People
+// can't appreciate their own work, so owners and members are refused.
+func loadAppreciableProject(tx *gorm.DB, projectID, userID string) (database.Project, error) {
+	var project database.Project
+	if err := tx.First(&project, "id = ?", projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return project, fiber.NewError(fiber.StatusNotFound, "Project not found")
+		}
+		return project, err
+	}
+	if project.PostedBy == userID {
+		return project, fiber.NewError(fiber.StatusForbidden, "You can't star or endorse your own project")
+	}
+	var members int64
+	if err := tx.Model(&database.ProjectMember{}).
+		Where("project_id = ? AND user_id = ? AND status = ?", projectID, userID, database.ProjectMemberAccepted).
+		Count(&members).Error; err != nil {
+		return project, err
+	}
+	if members > 0 {
+		return project, fiber.NewError(fiber.StatusForbidden, "You can't star or endorse your own project")
+	}
+	return project, nil
+}
+
+// ToggleProjectStar stars a project, or removes the star if already given.
+func ToggleProjectStar(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	action := "starred"
+	var starCount int64
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		project, err := loadAppreciableProject(tx, c.Params("id"), userID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("project_id = ? AND user_id = ?", project.ID, userID).Delete(&database.ProjectStar{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			action = "unstarred"
+		} else if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&database.ProjectStar{ProjectID: project.ID, UserID: userID}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&database.ProjectStar{}).Where("project_id = ?", project.ID).Count(&starCount).Error
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to update star")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "action": action, "star_count": starCount})
+}
+
+// ToggleProjectEndorsement endorses one of the skills a project is tagged
+// with, or withdraws the endorsement if already given.
+func ToggleProjectEndorsement(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req struct {
+		Skill string `json:"skill"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Skill) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+
+	action := "endorsed"
+	var skill string
+	var count int64
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		project, err := loadAppreciableProject(tx, c.Params("id"), userID)
+		if err != nil {
+			return err
+		}
+
+		var tags []string
+		json.Unmarshal(project.Tags, &tags)
+		for _, tag := range tags {
+			if strings.EqualFold(strings.TrimSpace(tag), strings.TrimSpace(req.Skill)) {
+				skill = strings.TrimSpace(tag)
+				break
+			}
+		}
+		if skill == "" {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "Skill must be one of the project's tags")
+		}
+
+		result := tx.Where("project_id = ? AND user_id = ? AND skill = ?", project.ID, userID, skill).
+			Delete(&database.ProjectEndorsement{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			action = "removed"
+		} else if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&database.ProjectEndorsement{ProjectID: project.ID, UserID: userID, Skill: skill}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&database.ProjectEndorsement{}).
+			Where("project_id = ? AND skill = ?", project.ID, skill).
+			Count(&count).Error
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to update endorsement")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "action": action, "skill": skill, "count": count})
+}
+
+// GetProjectLeaderboard ranks projects by stars plus endorsements, optionally
+// within a department, project year or owner batch. Projects without any
+// stars or endorsements are left out.
+func GetProjectLeaderboard(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var filters struct {
+		Department  string               `query:"department"`
+		Year        int                  `query:"year"`
+		Batch       int                  `query:"batch"`
+		ProjectType database.ProjectType `query:"project_type"`
+		Limit       int                  `query:"limit"`
+	}
+	if err := c.QueryParser(&filters); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid query parameters"})
+	}
+	if filters.Limit <= 0 {
+		filters.Limit = 10
+	}
+	if filters.Limit > 50 {
+		filters.Limit = 50
+	}
+
+	session := database.Session.Db
+	query := session.Table("projects").
+		Select("projects.id, " + projectStarCountSQL + " AS star_count, " + projectEndorsementCountSQL + " AS endorsement_count").
+		Joins("JOIN users ON users.id = projects.posted_by").
+		Where(projectScoreSQL + " > 0")
+	if filters.Department != "" {
+		query = query.Where("LOWER(users.department) = LOWER(?)", strings.TrimSpace(filters.Department))
+	}
+	if filters.Year > 0 {
+		query = query.Where("projects.year = ?", filters.Year)
+	}
+	if filters.Batch > 0 {
+		query = query.Where("users.batch = ?", filters.Batch)
+	}
+	if filters.ProjectType != "" {
+		query = query.Where("projects.project_type = ?", filters.ProjectType)
+	}
+
+	var ranked []struct {
+		ID               string
+		StarCount        int64
+		EndorsementCount int64
+	}
+	if err := query.Order(projectScoreSQL + " DESC").
+		Order(projectStarCountSQL + " DESC").
+		Order("projects.created_at").
+		Limit(filters.Limit).
+		Scan(&ranked).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch leaderboard",
+		})
+	}
+
+	projectIDs := make([]string, 0, len(ranked))
+	for _, row := range ranked {
+		projectIDs = append(projectIDs, row.ID)
+	}
+	var projects []database.Project
+	if len(projectIDs) > 0 {
+		if err := session.Preload("User").Where("id IN ?", projectIDs).Find(&projects).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to fetch leaderboard",
+			})
+		}
+	}
+	projectMap := make(map[string]database.Project, len(projects))
+	ownerIDs := make([]string, 0, len(projects))
+	for _, project := range projects {
+		projectMap[project.ID] = project
+		ownerIDs = append(ownerIDs, project.PostedBy)
+	}
+
+	profileMap := make(map[string]database.UserProfile)
+	if len(ownerIDs) > 0 {
+		var profiles []database.UserProfile
+		session.Where("user_id IN ?", ownerIDs).Find(&profiles)
+		for _, profile := range profiles {
+			profileMap[profile.UserID] = profile
+		}
+	}
+	appreciation, err := loadProjectAppreciation(session, projectIDs, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch leaderboard",
+		})
+	}
+
+	leaderboard := make([]fiber.Map, 0, len(ranked))
+	for _, row := range ranked {
+		project, ok := projectMap[row.ID]
+		if !ok {
+			continue
+		}
+		var tags []string
+		json.Unmarshal(project.Tags, &tags)
+		userName := ""
+		if project.User.UserName != nil {
+			userName = *project.User.UserName
+		}
+
+		leaderboard = append(leaderboard, fiber.Map{
+			"rank":              len(leaderboard) + 1,
+			"id":                project.ID,
+			"title":             project.Title,
+			"description":       project.Description,
+			"tags":              tags,
+			"project_type":      project.ProjectType,
+			"year":              project.Year,
+			"status":            project.Status,
+			"repository":        projectRepository(project),
+			"star_count":        row.StarCount,
+			"endorsement_count": row.EndorsementCount,
+			"score":             row.StarCount + row.EndorsementCount,
+			"is_starred":        appreciation[project.ID].IsStarred,
+			"endorsements":      append(make([]ProjectEndorsementResponse, 0), appreciation[project.ID].Endorsements...),
+			"posted_by": fiber.Map{
+				"id":            project.User.ID,
+				"full_name":     project.User.FullName,
+				"username":      userName,
+				"profile_image": profileMap[project.PostedBy].ProfileImage,
+				"department":    project.User.Department,
+				"batch":         project.User.Batch,
+			},
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    fiber.Map{"leaderboard": leaderboard},
+	})
+}
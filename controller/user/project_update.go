@@ -0,0 +1,126 @@
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProjectRepositoryResponse struct {
+	Provider string `json:"provider"`
+	Owner    string `json:"owner"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+}
+
+// projectRepository describes the repository behind the project's code link,
+// or returns nil when the link is not on a known code host.
+func projectRepository(project database.Project) *ProjectRepositoryResponse {
+	if project.RepoProvider == "" {
+		return nil
+	}
+	ref := database.RepositoryRef{
+		Provider: project.RepoProvider,
+		Owner:    project.RepoOwner,
+		Name:     project.RepoName,
+	}
+	return &ProjectRepositoryResponse{
+		Provider: ref.Provider,
+		Owner:    ref.Owner,
+		Name:     ref.Name,
+		URL:      ref.URL(),
+	}
+}
+
+// UpdateProject replaces a project's details. The owner and maintainers can
+// edit; members and media are managed through their own endpoints.
+func UpdateProject(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req ProjectRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	if errors := validateProjectRequest(req); len(errors) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  errors,
+		})
+	}
+
+	session := database.Session.Db
+	project, canManage, err := projectAccess(session, c.Params("id"), userID)
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to update project")
+	}
+	if !canManage {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Not authorized to update this project",
+		})
+	}
+
+	before := project
+	applyProjectRequest(&project, req)
+	if project.MentorID != nil {
+		// The name follows the linked faculty mentor; see PUT /projects/:id/mentor
+		project.Mentor = before.Mentor
+	}
+	var tags, contributors []string
+	json.Unmarshal(project.Tags, &tags)
+	json.Unmarshal(project.Contributors, &contributors)
+
+	// The mentor vouched for the old content, so changes need a fresh review
+	reverify := isFacultyVerified(before) && (before.Title != project.Title ||
+		before.Description != project.Description ||
+		!bytes.Equal(before.Tags, project.Tags) ||
+		!bytes.Equal(before.Links, project.Links))
+
+	err = session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&project).Error; err != nil {
+			return err
+		}
+		if reverify {
+			if err := requestProjectVerification(tx, &project, userID); err != nil {
+				return err
+			}
+		}
+		// Endorsements only apply to skills the project is still tagged with
+		stale := tx.Where("project_id = ?", project.ID)
+		if len(tags) > 0 {
+			stale = stale.Where("skill NOT IN ?", tags)
+		}
+		return stale.Delete(&database.ProjectEndorsement{}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update project",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id":                  project.ID,
+			"title":               project.Title,
+			"description":         project.Description,
+			"tags":                tags,
+			"project_type":        project.ProjectType,
+			"year":                project.Year,
+			"mentor":              project.Mentor,
+			"contributors":        contributors,
+			"links":               req.Links,
+			"repository":          projectRepository(project),
+			"status":              project.Status,
+			"verification_status": project.VerificationStatus,
+			"updated_at":          project.UpdatedAt,
+		},
+	})
+}
@@ -0,0 +1,387 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gradspaceBK/database"
+	"gradspaceBK/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// isFacultyVerified reports whether the project carries the "verified by
+// faculty" badge.
+func isFacultyVerified(project database.Project) bool {
+	return project.MentorID != nil && project.VerificationStatus == database.ProjectVerificationVerified
+}
+
+// loadProjectMentors returns the linked faculty mentor of each project, keyed
+// by project ID. Unless withPending is set, mentors are only shown once they
+// have verified the project.
+func loadProjectMentors(session *gorm.DB, projects []database.Project, withPending bool) (map[string]*PosterResponse, error) {
+	mentors := make(map[string]*PosterResponse)
+	mentorIDs := make([]string, 0, len(projects))
+	for _, project := range projects {
+		if project.MentorID != nil && (withPending || isFacultyVerified(project)) {
+			mentorIDs = append(mentorIDs, *project.MentorID)
+		}
+	}
+	if len(mentorIDs) == 0 {
+		return mentors, nil
+	}
+
+	var users []database.User
+	if err := session.Where("id IN ?", mentorIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	var profiles []database.UserProfile
+	if err := session.Where("user_id IN ?", mentorIDs).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	profileMap := make(map[string]database.UserProfile)
+	for _, profile := range profiles {
+		profileMap[profile.UserID] = profile
+	}
+	userMap := make(map[string]*PosterResponse)
+	for _, user := range users {
+		userName := ""
+		if user.UserName != nil {
+			userName = *user.UserName
+		}
+		userMap[user.ID] = &PosterResponse{
+			ID:           user.ID,
+			FullName:     user.FullName,
+			UserName:     userName,
+			ProfileImage: profileMap[user.ID].ProfileImage,
+		}
+	}
+
+	for _, project := range projects {
+		if project.MentorID != nil && (withPending || isFacultyVerified(project)) {
+			mentors[project.ID] = userMap[*project.MentorID]
+		}
+	}
+	return mentors, nil
+}
+
+// requestProjectVerification puts the project in front of its mentor again
+// and notifies them.
+func requestProjectVerification(tx *gorm.DB, project *database.Project, requesterID string) error {
+	project.VerificationStatus = database.ProjectVerificationPending
+	project.VerificationNote = ""
+	project.VerifiedAt = nil
+	if err := tx.Model(project).Updates(map[string]interface{}{
+		"verification_status": project.VerificationStatus,
+		"verification_note":   "",
+		"verified_at":         nil,
+	}).Error; err != nil {
+		return err
+	}
+
+	notification := database.Notification{
+		UserID:    *project.MentorID,
+		CreatorID: requesterID,
+		Type:      database.NotificationTypeProjectVerificationRequest,
+		ProjectID: &project.ID,
+		Message:   fmt.Sprintf("You were asked to verify \"%s\" as its faculty mentor.", project.Title),
+	}
+	return tx.Create(&notification).Error
+}
+
+// SetProjectMentor links a Faculty user as the project's mentor and asks them
+// to verify it. Sending an empty mentor_id unlinks the mentor and removes
+// the badge. Only the owner can do this.
+func SetProjectMentor(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req struct {
+		MentorID string `json:"mentor_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	req.MentorID = strings.TrimSpace(req.MentorID)
+
+	var project database.Project
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&project, "id = ?", c.Params("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "Project not found")
+			}
+			return err
+		}
+		if project.PostedBy != userID {
+			return fiber.NewError(fiber.StatusForbidden, "Only the project owner can change its mentor")
+		}
+
+		if req.MentorID == "" {
+			project.MentorID = nil
+			project.VerificationStatus = database.ProjectVerificationNone
+			project.VerificationNote = ""
+			project.VerifiedAt = nil
+			return tx.Model(&project).Updates(map[string]interface{}{
+				"mentor_id":           nil,
+				"verification_status": project.VerificationStatus,
+				"verification_note":   "",
+				"verified_at":         nil,
+			}).Error
+		}
+
+		if req.MentorID == userID {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "You can't mentor your own project")
+		}
+		var mentor database.User
+		if err := tx.First(&mentor, "id = ?", req.MentorID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Mentor not found")
+		}
+		if mentor.Role != "Faculty" {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "Mentor must be a faculty member")
+		}
+
+		// Asking the same mentor again is allowed unless they already verified it
+		if project.MentorID != nil && *project.MentorID == mentor.ID {
+			switch project.VerificationStatus {
+			case database.ProjectVerificationVerified:
+				return fiber.NewError(fiber.StatusConflict, "Project is already verified by this mentor")
+			case database.ProjectVerificationPending:
+				return fiber.NewError(fiber.StatusConflict, "Verification is already pending with this mentor")
+			}
+		}
+
+		project.MentorID = &mentor.ID
+		project.Mentor = mentor.FullName
+		if err := tx.Model(&project).Updates(map[string]interface{}{
+			"mentor_id": mentor.ID,
+			"mentor":    mentor.FullName,
+		}).Error; err != nil {
+			return err
+		}
+		return requestProjectVerification(tx, &project, userID)
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to update mentor")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"id":                  project.ID,
+			"mentor":              project.Mentor,
+			"mentor_id":           project.MentorID,
+			"verification_status": project.VerificationStatus,
+		},
+	})
+}
+
+// GetPendingProjectVerifications lists the projects waiting for the current
+// faculty member's verification, oldest request first.
+func GetPendingProjectVerifications(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var pagination struct {
+		Page  int `query:"page"`
+		Limit int `query:"limit"`
+	}
+	if err := c.QueryParser(&pagination); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid query"})
+	}
+	if pagination.Page == 0 {
+		pagination.Page = 1
+	}
+	if pagination.Limit == 0 {
+		pagination.Limit = 10
+	}
+
+	session := database.Session.Db
+	query := session.Model(&database.Project{}).
+		Where("mentor_id = ? AND verification_status = ?", userID, database.ProjectVerificationPending)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to count pending verifications",
+		})
+	}
+
+	var projects []database.Project
+	if err := query.Preload("User").
+		Order("updated_at").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&projects).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch pending verifications",
+		})
+	}
+
+	projectIDs := make([]string, 0, len(projects))
+	ownerIDs := make([]string, 0, len(projects))
+	for _, project := range projects {
+		projectIDs = append(projectIDs, project.ID)
+		ownerIDs = append(ownerIDs, project.PostedBy)
+	}
+	profileMap := make(map[string]database.UserProfile)
+	if len(ownerIDs) > 0 {
+		var profiles []database.UserProfile
+		session.Where("user_id IN ?", ownerIDs).Find(&profiles)
+		for _, profile := range profiles {
+			profileMap[profile.UserID] = profile
+		}
+	}
+	membersByProject, err := loadProjectMembers(session, projectIDs, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch project members",
+		})
+	}
+
+	pending := make([]fiber.Map, 0, len(projects))
+	for _, project := range projects {
+		var tags, contributors []string
+		json.Unmarshal(project.Tags, &tags)
+		json.Unmarshal(project.Contributors, &contributors)
+		var links database.ProjectLinks
+		json.Unmarshal(project.Links, &links)
+		userName := ""
+		if project.User.UserName != nil {
+			userName = *project.User.UserName
+		}
+
+		pending = append(pending, fiber.Map{
+			"id":           project.ID,
+			"title":        project.Title,
+			"description":  project.Description,
+			"tags":         tags,
+			"project_type": project.ProjectType,
+			"year":         project.Year,
+			"contributors": contributors,
+			"members":      append(make([]ProjectMemberResponse, 0), membersByProject[project.ID]...),
+			"links":        links,
+			"repository":   projectRepository(project),
+			"status":       project.Status,
+			"requested_at": project.UpdatedAt,
+			"posted_by": fiber.Map{
+				"id":            project.User.ID,
+				"full_name":     project.User.FullName,
+				"username":      userName,
+				"profile_image": profileMap[project.PostedBy].ProfileImage,
+				"department":    project.User.Department,
+				"batch":         project.User.Batch,
+			},
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"projects": pending,
+			"pagination": fiber.Map{
+				"total": total,
+				"page":  pagination.Page,
+				"limit": pagination.Limit,
+			},
+		},
+	})
+}
+
+// VerifyProject lets the linked faculty mentor approve or reject a project.
+// A note is required for rejections. The owner is notified either way.
+func VerifyProject(c *fiber.Ctx) error {
+	userID := middlewares.AuthUserID(c)
+
+	var req struct {
+		Decision string `json:"decision"` // "approve" or "reject"
+		Note     string `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request"})
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	var status database.ProjectVerificationStatus
+	var notificationType database.NotificationType
+	switch req.Decision {
+	case "approve":
+		status = database.ProjectVerificationVerified
+		notificationType = database.NotificationTypeProjectVerified
+	case "reject":
+		if req.Note == "" {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"success": false,
+				"errors":  fiber.Map{"note": "Note is required when rejecting a project"},
+			})
+		}
+		status = database.ProjectVerificationRejected
+		notificationType = database.NotificationTypeProjectVerificationRejected
+	default:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"errors":  fiber.Map{"decision": "Decision must be approve or reject"},
+		})
+	}
+
+	var project database.Project
+	now := time.Now()
+	err := database.Session.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&project, "id = ? AND mentor_id = ?", c.Params("id"), userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "Project not found or you are not its mentor")
+			}
+			return err
+		}
+		if project.VerificationStatus != database.ProjectVerificationPending {
+			return fiber.NewError(fiber.StatusConflict, "Project is not awaiting verification")
+		}
+
+		updates := map[string]interface{}{
+			"verification_status": status,
+			"verification_note":   req.Note,
+			"verified_at":         nil,
+		}
+		if status == database.ProjectVerificationVerified {
+			updates["verified_at"] = now
+		}
+		if err := tx.Model(&project).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("\"%s\" is now verified by your faculty mentor.", project.Title)
+		if status == database.ProjectVerificationRejected {
+			message = fmt.Sprintf("Your faculty mentor did not verify \"%s\". Note: %s", project.Title, req.Note)
+		} else if req.Note != "" {
+			message += " Note: " + req.Note
+		}
+		notification := database.Notification{
+			UserID:    project.PostedBy,
+			CreatorID: userID,
+			Type:      notificationType,
+			ProjectID: &project.ID,
+			Message:   message,
+		}
+		return tx.Create(&notification).Error
+	})
+	if err != nil {
+		return projectErrorResponse(c, err, "Failed to verify project")
+	}
+
+	response := fiber.Map{
+		"id":                  project.ID,
+		"verification_status": status,
+		"verification_note":   req.Note,
+	}
+	if status == database.ProjectVerificationVerified {
+		response["verified_at"] = now
+	}
+	return c.JSON(fiber.Map{"success": true, "data": response})
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeletedUserName is shown in place of an erased user's name.
+const DeletedUserName = "Deleted user"
+
+// UserUploadPaths returns the files a user uploaded that are still stored:
+// their profile image, post images and project media. Paths outside the
+// uploads directory are left out.
+func UserUploadPaths(tx *gorm.DB, userID string) ([]string, error) {
+	var paths, more []string
+	if err := tx.Model(&UserProfile{}).Where("user_id = ?", userID).Pluck("profile_image", &paths).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&Post{}).Where("author_id = ? AND image IS NOT NULL", userID).Pluck("image", &more).Error; err != nil {
+		return nil, err
+	}
+	paths = append(paths, more...)
+	if err := tx.Model(&ProjectMedia{}).
+		Where("uploaded_by = ? OR project_id IN (?)", userID, tx.Model(&Project{}).Select("id").Where("posted_by = ?", userID)).
+		Pluck("path", &more).Error; err != nil {
+		return nil, err
+	}
+	paths = append(paths, more...)
+
+	uploads := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if !strings.HasPrefix(path, "uploads"+string(filepath.Separator)) || seen[path] {
+			continue
+		}
+		seen[path] = true
+		uploads = append(uploads, path)
+	}
+	return uploads, nil
+}
+
+// EraseUser deletes everything a user created except their messages, and
+// anonymizes the user row so those messages keep a sender. Uploaded files are
+// not touched; collect them with UserUploadPaths first and remove them once
+// the transaction commits.
+func EraseUser(tx *gorm.DB, userID string) error {
+	var user User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	id := sql.Named("id", userID)
+	// Posts, jobs, events and projects take their comments, reports, RSVPs,
+	// media and so on with them through their foreign keys
+	deletes := []struct {
+		model interface{}
+		where string
+	}{
+		{&Verification{}, "user_id = @id"},
+		{&UserSession{}, "user_id = @id"},
+		{&RecoveryCode{}, "user_id = @id"},
+		{&LoginAudit{}, "user_id = @id"},
+		{&UserProfile{}, "user_id = @id"},
+		{&SocialLinks{}, "user_id = @id"},
+		{&Experience{}, "user_id = @id"},
+		{&Education{}, "user_id = @id"},
+		{&Post{}, "author_id = @id"},
+		{&Comment{}, "author_id = @id"},
+		{&Like{}, "user_id = @id"},
+		{&Follow{}, "follower_id = @id OR following_id = @id"},
+		{&Notification{}, "user_id = @id OR creator_id = @id"},
+		{&CompanyFollow{}, "user_id = @id"},
+		{&SavedJob{}, "user_id = @id"},
+		{&JobReport{}, "reporter_id = @id"},
+		{&Job{}, "posted_by = @id"},
+		{&SavedEvent{}, "user_id = @id"},
+		{&EventRSVP{}, "user_id = @id"},
+		{&EventReminder{}, "user_id = @id"},
+		{&CalendarFeed{}, "user_id = @id"},
+		{&Event{}, "posted_by = @id"},
+		{&ProjectMedia{}, "uploaded_by = @id"},
+		{&ProjectMember{}, "user_id = @id"},
+		{&ProjectStar{}, "user_id = @id"},
+		{&ProjectEndorsement{}, "user_id = @id"},
+		{&SavedProject{}, "user_id = @id"},
+		{&Project{}, "posted_by = @id"},
+	}
+	for _, d := range deletes {
+		if err := tx.Where(d.where, id).Delete(d.model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("scope = ? AND subject = ?", ThrottleScopeAccount, userID).Delete(&LoginThrottle{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("LOWER(email) = ?", strings.ToLower(user.Email)).Delete(&RegisterRequest{}).Error; err != nil {
+		return err
+	}
+	// UpdateColumn skips the BeforeSave hooks, which would run against an
+	// empty model; Company's rejects it for having no name
+	if err := tx.Model(&Project{}).Where("mentor_id = ?", userID).UpdateColumn("mentor_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Company{}).Where("created_by = ?", userID).UpdateColumn("created_by", nil).Error; err != nil {
+		return err
+	}
+
+	// The email must stay unique and not null; .invalid can never be delivered to
+	return tx.Model(&user).Updates(map[string]interface{}{
+		"full_name":           DeletedUserName,
+		"user_name":           nil,
+		"department":          "",
+		"batch":               0,
+		"email":               "deleted-" + userID + "@deleted.invalid",
+		"password":            "",
+		"is_verified":         false,
+		"is_onboard":          false,
+		"registration_status": "deleted",
+		"two_factor_enabled":  false,
+		"totp_secret":         "",
+		"totp_last_step":      0,
+		"oidc_subject":        nil,
+		"deletion_due_at":     nil,
+		"anonymized_at":       time.Now(),
+	}).Error
+}
@@ -29,6 +29,9 @@ func DBConnection() {
 	)
 	db, err := gorm.Open(postgres.Open(postgresDSN), &gorm.Config{
 		SkipDefaultTransaction: true,
+		// Constraint violations come back as gorm errors such as
+		// gorm.ErrDuplicatedKey, so handlers can tell them apart
+		TranslateError: true,
 	})
 	if err != nil {
 		log.Fatal(err)
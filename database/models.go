@@ -1,11 +1,17 @@
 package database
 
 import (
+	"encoding/json"
 	"errors"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BaseModel struct {
@@ -24,13 +30,67 @@ type RegisterRequest struct {
 	Role        string `gorm:"size:255;not null"`
 }
 
+// VerificationPurpose marks emailed codes that are not for sign-up, a
+// password reset or an email change, which are told apart by their other
+// columns.
+type VerificationPurpose string
+
+const (
+	// A code that stands in for the password before a sensitive change, for
+	// accounts that only sign in through SSO
+	VerificationPurposeReauth VerificationPurpose = "REAUTH"
+)
+
 type Verification struct {
 	BaseModel          `gorm:"embedded"`
-	UserID             string    `gorm:"not null;size:36"`
-	VerificationToken  string    `gorm:"size:6"`
-	User               User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	ResetPasswordToken string    `gorm:"size:36"`
-	ExpiresAt          time.Time `gorm:"type:timestamp"`
+	UserID             string              `gorm:"not null;size:36"`
+	VerificationToken  string              `gorm:"size:6"`
+	User               User                `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ResetPasswordToken string              `gorm:"size:64"` // SHA-256 of the emailed token
+	ExpiresAt          time.Time           `gorm:"type:timestamp"`
+	Attempts           int                 `gorm:"not null;default:0"`            // Wrong OTP guesses against this code
+	NewEmail           string              `gorm:"size:255;not null;default:''"` // Set for email changes: the address the code was sent to
+	Purpose            VerificationPurpose `gorm:"size:20;not null;default:''"`
+}
+
+type ThrottleScope string
+
+const (
+	ThrottleScopeAccount ThrottleScope = "ACCOUNT"
+	ThrottleScopeIP      ThrottleScope = "IP"
+)
+
+// LoginThrottle counts recent failed logins for an account (by user ID) or a
+// client IP. Rows are removed after a successful login or an unlock.
+type LoginThrottle struct {
+	BaseModel    `gorm:"embedded"`
+	Scope        ThrottleScope `gorm:"size:20;not null;uniqueIndex:idx_login_throttle"`
+	Subject      string        `gorm:"size:64;not null;uniqueIndex:idx_login_throttle"`
+	Failures     int           `gorm:"not null;default:0"`
+	LastFailedAt time.Time
+	BlockedUntil *time.Time
+	Locked       bool `gorm:"not null;default:false"` // Blocked by a lockout rather than backoff
+}
+
+type LoginFailureReason string
+
+const (
+	LoginFailureUnknownEmail LoginFailureReason = "UNKNOWN_EMAIL"
+	LoginFailureBadPassword  LoginFailureReason = "BAD_PASSWORD"
+	LoginFailureThrottled    LoginFailureReason = "THROTTLED"
+	LoginFailureLocked       LoginFailureReason = "LOCKED"
+	LoginFailureBadTOTP      LoginFailureReason = "BAD_2FA_CODE"
+)
+
+// LoginAudit records a failed login attempt.
+type LoginAudit struct {
+	BaseModel `gorm:"embedded"`
+	UserID    *string            `gorm:"size:36;index:idx_login_audit_user"`
+	Email     string             `gorm:"size:255;not null"`
+	IPAddress string             `gorm:"size:45;not null;index:idx_login_audit_ip"`
+	UserAgent string             `gorm:"size:255"`
+	Reason    LoginFailureReason `gorm:"size:20;not null"`
+	User      *User              `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
 
 type User struct {
@@ -45,6 +105,40 @@ type User struct {
 	RegistrationStatus string  `gorm:"not null;size:100;default:'not_registered'"`
 	Email              string  `gorm:"unique;not null;size:255"`
 	Password           string  `gorm:"size:255"`
+	TwoFactorEnabled   bool    `gorm:"not null;default:false"`
+	TOTPSecret         string  `gorm:"size:64"`                                  // Set on setup; only used once enabled
+	TOTPLastStep       int64   `gorm:"not null;default:0"`                       // Last accepted time step, to stop code replay
+	OIDCSubject        *string `gorm:"column:oidc_subject;size:255;uniqueIndex"` // Linked on first single sign-on
+
+	// Self-service deletion: the account is erased once DeletionDueAt passes.
+	// The row itself is kept, anonymized, so the other side of the user's
+	// conversations still has a sender to show.
+	DeletionDueAt *time.Time `gorm:"index:idx_user_deletion_due"`
+	AnonymizedAt  *time.Time
+}
+
+// UserSession is one login on one device. Its ID is the "sid" claim of the
+// tokens issued for it, and AuthMiddleware refuses tokens whose session was
+// revoked or deleted.
+type UserSession struct {
+	BaseModel  `gorm:"embedded"`
+	UserID     string `gorm:"size:36;not null;index:idx_user_session_user"`
+	UserAgent  string `gorm:"size:255"`
+	IPAddress  string `gorm:"size:45"`
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	User       User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// RecoveryCode is a one-time code that stands in for a TOTP code when the
+// authenticator is lost. Only a hash of the code is stored.
+type RecoveryCode struct {
+	BaseModel `gorm:"embedded"`
+	UserID    string `gorm:"size:36;not null;index:idx_recovery_code_user"`
+	CodeHash  string `gorm:"size:64;not null;uniqueIndex"`
+	UsedAt    *time.Time
+	User      User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
 type UserProfile struct {
@@ -74,6 +168,8 @@ type Experience struct {
 	BaseModel    `gorm:"embedded"`
 	UserID       string `gorm:"size:36;not null"`
 	CompanyName  string `gorm:"size:255;not null"`
+	CompanyID    *string `gorm:"size:36;index:idx_experience_company"` // Set when merged into a known company
+	NormalizedCompanyName string `gorm:"size:255;not null;default:'';index:idx_experience_normalized_company"` // Set from CompanyName on save
 	Position     string `gorm:"size:255;not null"`
 	StartDate    time.Time
 	EndDate      *time.Time `gorm:"null"`
@@ -83,6 +179,20 @@ type Experience struct {
 	User         User       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
+// BeforeSave keeps NormalizedCompanyName in step with CompanyName, so
+// unlinked experiences match companies the way companies are deduplicated.
+func (e *Experience) BeforeSave(tx *gorm.DB) error {
+	// Column updates run the hook on an empty model; only follow a new name
+	if updates, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		if name, ok := updates["company_name"].(string); ok {
+			tx.Statement.SetColumn("NormalizedCompanyName", NormalizeCompanyName(name))
+		}
+		return nil
+	}
+	e.NormalizedCompanyName = NormalizeCompanyName(e.CompanyName)
+	return nil
+}
+
 type Education struct {
 	BaseModel       `gorm:"embedded"`
 	UserID          string `gorm:"size:36;not null"`
@@ -101,6 +211,24 @@ const (
 	NotificationTypeLike    NotificationType = "LIKE"
 	NotificationTypeComment NotificationType = "COMMENT"
 	NotificationTypeFollow  NotificationType = "FOLLOW"
+
+	NotificationTypeJobHidden   NotificationType = "JOB_HIDDEN"
+	NotificationTypeJobTakedown NotificationType = "JOB_TAKEDOWN"
+	NotificationTypeCompanyJob  NotificationType = "COMPANY_JOB"
+
+	NotificationTypeEventPromoted NotificationType = "EVENT_WAITLIST_PROMOTED"
+	NotificationTypeEventReminder NotificationType = "EVENT_REMINDER"
+	NotificationTypeEventUpdated  NotificationType = "EVENT_UPDATED"
+	NotificationTypeEventCancelled NotificationType = "EVENT_CANCELLED"
+	NotificationTypeEventApproved  NotificationType = "EVENT_APPROVED"
+	NotificationTypeEventRejected  NotificationType = "EVENT_REJECTED"
+	NotificationTypeEventReviewRequested NotificationType = "EVENT_REVIEW_REQUESTED"
+
+	NotificationTypeProjectInvite   NotificationType = "PROJECT_INVITE"
+	NotificationTypeProjectAccepted NotificationType = "PROJECT_INVITE_ACCEPTED"
+	NotificationTypeProjectVerificationRequest  NotificationType = "PROJECT_VERIFICATION_REQUEST"
+	NotificationTypeProjectVerified             NotificationType = "PROJECT_VERIFIED"
+	NotificationTypeProjectVerificationRejected NotificationType = "PROJECT_VERIFICATION_REJECTED"
 )
 
 type Post struct {
@@ -146,12 +274,19 @@ type Notification struct {
 	Read      bool             `gorm:"not null;default:false"`
 	PostID    *string          `gorm:"size:36"` // Nullable (for FOLLOW-type notifications)
 	CommentID *string          `gorm:"size:36"` // Nullable (for LIKE/FOLLOW notifications)
+	JobID     *string          `gorm:"size:36"` // Nullable (only for JOB_* notifications)
+	EventID   *string          `gorm:"size:36"` // Nullable (only for EVENT_* notifications)
+	ProjectID *string          `gorm:"size:36"` // Nullable (only for PROJECT_* notifications)
+	Message   string           `gorm:"type:text"`
 
 	// Relationships
 	User    User     `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Creator User     `gorm:"foreignKey:CreatorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Post    *Post    `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	Comment *Comment `gorm:"foreignKey:CommentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Job     *Job     `gorm:"foreignKey:JobID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Event   *Event   `gorm:"foreignKey:EventID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Project *Project `gorm:"foreignKey:ProjectID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 
 	// Composite index for sorting
 	CreatedAt time.Time `gorm:"index:idx_notification_user_created,sort:desc"`
@@ -164,7 +299,15 @@ func (n *Notification) BeforeCreate(tx *gorm.DB) error {
 	}
 	// Validate notification type
 	switch n.Type {
-	case NotificationTypeLike, NotificationTypeComment, NotificationTypeFollow:
+	case NotificationTypeLike, NotificationTypeComment, NotificationTypeFollow,
+		NotificationTypeJobHidden, NotificationTypeJobTakedown, NotificationTypeCompanyJob,
+		NotificationTypeEventPromoted, NotificationTypeEventReminder,
+		NotificationTypeEventUpdated, NotificationTypeEventCancelled,
+		NotificationTypeEventApproved, NotificationTypeEventRejected,
+		NotificationTypeEventReviewRequested,
+		NotificationTypeProjectInvite, NotificationTypeProjectAccepted,
+		NotificationTypeProjectVerificationRequest, NotificationTypeProjectVerified,
+		NotificationTypeProjectVerificationRejected:
 		return nil
 	default:
 		return errors.New("invalid notification type")
@@ -174,9 +317,56 @@ func (n *Notification) BeforeCreate(tx *gorm.DB) error {
 // Job Portal Models
 
 type Company struct {
+	BaseModel      `gorm:"embedded"`
+	Name           string  `gorm:"size:255;not null"`
+	NormalizedName *string `gorm:"size:255;uniqueIndex:idx_company_normalized_name"` // Set from Name on save
+	LogoURL        string  `gorm:"size:255;null"`
+	Description    string  `gorm:"type:text;null"`
+	Website        string  `gorm:"size:255;null"`
+	Industry       string  `gorm:"size:255;null"`
+	Headquarters   string  `gorm:"size:255;null"`
+	CreatedBy      *string `gorm:"size:36"` // Null for companies created before ownership was tracked
+
+	Creator        *User   `gorm:"foreignKey:CreatedBy;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+// Legal suffixes dropped when normalizing company names, so that "Google",
+// "google inc" and "Google LLC" are treated as the same company.
+var companySuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "llc": true, "llp": true, "ltd": true,
+	"limited": true, "corp": true, "corporation": true, "co": true,
+	"company": true, "pvt": true, "private": true, "plc": true, "gmbh": true,
+}
+
+// NormalizeCompanyName lowercases name, drops punctuation and trailing legal
+// suffixes. It never returns an empty string for a non-empty name.
+func NormalizeCompanyName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	end := len(words)
+	for end > 1 && companySuffixes[words[end-1]] {
+		end--
+	}
+	return strings.Join(words[:end], " ")
+}
+
+func (c *Company) BeforeSave(tx *gorm.DB) error {
+	normalized := NormalizeCompanyName(c.Name)
+	if normalized == "" {
+		return errors.New("company name is required")
+	}
+	c.NormalizedName = &normalized
+	return nil
+}
+
+type CompanyFollow struct {
 	BaseModel   `gorm:"embedded"`
-	Name        string `gorm:"size:255;not null"`
-	LogoURL     string `gorm:"size:255;null"`
+	UserID      string `gorm:"size:36;not null;uniqueIndex:idx_company_follow_user"`
+	CompanyID   string `gorm:"size:36;not null;uniqueIndex:idx_company_follow_user;index:idx_company_follow_company"`
+
+	User        User    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Company     Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
 type Job struct {
@@ -188,11 +378,13 @@ type Job struct {
 	Location     string   `gorm:"size:255;not null"`
 	Requirements string   `gorm:"type:text;not null"`
 	IsOpen       bool     `gorm:"not null;default:true"`
+	IsHidden     bool     `gorm:"not null;default:false"` // Hidden by reports or admin takedown
 	JobType      string   `gorm:"size:50;not null"`
 	ApplyLink    string   `gorm:"size:255;null"`
+	ExternalRef  *string  `gorm:"size:255;uniqueIndex:idx_job_external_ref"` // Set by bulk imports
 	
 	PostedByUser User     `gorm:"foreignKey:PostedBy;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	Company      Company  `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Company      Company  `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
 }
 
 type SavedJob struct {
@@ -204,12 +396,21 @@ type SavedJob struct {
 	Job         Job     `gorm:"foreignKey:JobID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
+type JobReportStatus string
+
+const (
+	JobReportStatusPending   JobReportStatus = "PENDING"
+	JobReportStatusDismissed JobReportStatus = "DISMISSED"
+	JobReportStatusActioned  JobReportStatus = "ACTIONED"
+)
+
 type JobReport struct {
 	BaseModel     `gorm:"embedded"`
-	JobID         string `gorm:"size:36;not null"`
+	JobID         string `gorm:"size:36;not null;uniqueIndex:idx_job_report_reporter"`
 	Reason        string `gorm:"size:255;not null"`
 	JobPosterID   string `gorm:"size:36;not null"`
-	ReporterID    string `gorm:"size:36;not null"`
+	ReporterID    string `gorm:"size:36;not null;uniqueIndex:idx_job_report_reporter"`
+	Status        JobReportStatus `gorm:"size:20;not null;default:'PENDING';index:idx_job_report_status"`
 	
 	Job          Job     `gorm:"foreignKey:JobID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	JobPoster    User    `gorm:"foreignKey:JobPosterID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
@@ -252,6 +453,24 @@ const (
     EventTypeAlum   EventType = "ALUM_EVENT"
 )
 
+type EventStatus string
+
+const (
+    EventStatusScheduled EventStatus = "SCHEDULED"
+    // Cancelled events stay listed so attendees can see what happened
+    EventStatusCancelled EventStatus = "CANCELLED"
+)
+
+// Campus events posted by students and alumni are reviewed by faculty before
+// they are listed; everything else is approved on creation.
+type EventApprovalStatus string
+
+const (
+    EventApprovalPending  EventApprovalStatus = "PENDING"
+    EventApprovalApproved EventApprovalStatus = "APPROVED"
+    EventApprovalRejected EventApprovalStatus = "REJECTED"
+)
+
 type Event struct {
     BaseModel          `gorm:"embedded"`
     Title              string     `gorm:"size:255;not null"`
@@ -262,9 +481,37 @@ type Event struct {
     StartDateTime      time.Time  `gorm:"not null;index:idx_event_time"`
     EndDateTime        time.Time  `gorm:"not null"`
     IsRegistrationOpen bool       `gorm:"not null;default:true"`
+    Capacity           *int       `gorm:"null"`                 // Nil means unlimited RSVPs
+    Status             EventStatus `gorm:"size:20;not null;default:'SCHEDULED'"`
+    CancellationReason string     `gorm:"type:text"`
+    CancelledAt        *time.Time `gorm:"null"`
+    ApprovalStatus     EventApprovalStatus `gorm:"size:20;not null;default:'APPROVED';index:idx_event_approval"`
+    ReviewedBy         *string    `gorm:"size:36"`
+    ReviewedAt         *time.Time `gorm:"null"`
+    ReviewNote         string     `gorm:"type:text"`     // Reason given with the decision
     PostedBy           string     `gorm:"size:36;not null;index:idx_event_owner"`
+    // Recurring series: the master row holds the rule, StartDateTime/EndDateTime
+    // are the first occurrence and RecurrenceEnd the start of the last one
+    // (nil when the series has no end)
+    RecurrenceRule     *string    `gorm:"size:255"`
+    RecurrenceEnd      *time.Time `gorm:"null"`
+    // An edited occurrence is stored as its own row pointing at the master,
+    // keyed by the start time it replaces
+    SeriesID           *string    `gorm:"size:36;uniqueIndex:idx_event_occurrence"`
+    OccurrenceStart    *time.Time `gorm:"uniqueIndex:idx_event_occurrence"`
     // Relationships
     User               User       `gorm:"foreignKey:PostedBy;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    Series             *Event     `gorm:"foreignKey:SeriesID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    Exceptions         []EventException `gorm:"foreignKey:EventID"`
+}
+
+// EventException removes one occurrence from a recurring series (EXDATE).
+type EventException struct {
+    BaseModel       `gorm:"embedded"`
+    EventID         string    `gorm:"size:36;not null;uniqueIndex:idx_event_exception"`
+    OccurrenceStart time.Time `gorm:"not null;uniqueIndex:idx_event_exception"`
+
+    Event           Event     `gorm:"foreignKey:EventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
 type SavedEvent struct {
@@ -276,6 +523,67 @@ type SavedEvent struct {
     Event       Event `gorm:"foreignKey:EventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
+type RSVPStatus string
+
+const (
+    RSVPStatusGoing      RSVPStatus = "GOING"
+    RSVPStatusWaitlisted RSVPStatus = "WAITLISTED"
+    RSVPStatusCancelled  RSVPStatus = "CANCELLED"
+)
+
+type EventRSVP struct {
+    BaseModel   `gorm:"embedded"`
+    EventID     string     `gorm:"size:36;not null;uniqueIndex:idx_event_rsvp_user;index:idx_event_rsvp_status"`
+    UserID      string     `gorm:"size:36;not null;uniqueIndex:idx_event_rsvp_user"`
+    Status      RSVPStatus `gorm:"size:20;not null;index:idx_event_rsvp_status"`
+    RespondedAt time.Time  `gorm:"not null"`             // Waitlist order; reset when re-joining
+    CheckedInAt *time.Time `gorm:"null"`
+
+    User        User  `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    Event       Event `gorm:"foreignKey:EventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type ReminderStatus string
+
+const (
+    ReminderStatusPending ReminderStatus = "PENDING"
+    ReminderStatusSending ReminderStatus = "SENDING" // Claimed by a scheduler instance
+    ReminderStatusSent    ReminderStatus = "SENT"
+    ReminderStatusSkipped ReminderStatus = "SKIPPED"
+)
+
+// EventReminderOffsets are how long before an event starts reminders go out.
+var EventReminderOffsets = map[string]time.Duration{
+    "24h": 24 * time.Hour,
+    "1h":  time.Hour,
+}
+
+// EventReminder is a scheduled reminder for one user about one event. The
+// unique index guarantees a reminder is only ever scheduled once per offset.
+type EventReminder struct {
+    BaseModel `gorm:"embedded"`
+    EventID   string         `gorm:"size:36;not null;uniqueIndex:idx_event_reminder"`
+    UserID    string         `gorm:"size:36;not null;uniqueIndex:idx_event_reminder"`
+    Kind      string         `gorm:"size:10;not null;uniqueIndex:idx_event_reminder"` // Key of EventReminderOffsets
+    SendAt    time.Time      `gorm:"not null;index:idx_event_reminder_due"`
+    Status    ReminderStatus `gorm:"size:20;not null;default:'PENDING';index:idx_event_reminder_due"`
+    ClaimedAt *time.Time     `gorm:"null"`
+    SentAt    *time.Time     `gorm:"null"`
+
+    User      User  `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    Event     Event `gorm:"foreignKey:EventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// CalendarFeed holds the secret token behind a user's subscribable .ics feed.
+// Rotating the token invalidates previously shared feed URLs.
+type CalendarFeed struct {
+    BaseModel `gorm:"embedded"`
+    UserID    string `gorm:"size:36;not null;uniqueIndex"`
+    Token     string `gorm:"size:64;not null;uniqueIndex"`
+
+    User      User   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
 // project shelf
 type ProjectType string
 
@@ -292,6 +600,16 @@ const (
     ProjectStatusCompleted ProjectStatus = "COMPLETED"
 )
 
+// Whether the linked faculty mentor has vouched for the project
+type ProjectVerificationStatus string
+
+const (
+    ProjectVerificationNone     ProjectVerificationStatus = "UNVERIFIED" // No faculty mentor linked
+    ProjectVerificationPending  ProjectVerificationStatus = "PENDING"
+    ProjectVerificationVerified ProjectVerificationStatus = "VERIFIED"
+    ProjectVerificationRejected ProjectVerificationStatus = "REJECTED"
+)
+
 type ProjectLinks struct {
     CodeLink string `json:"code_link,omitempty"`
     Video    string `json:"video,omitempty"`
@@ -307,13 +625,184 @@ type Project struct {
     ProjectType        ProjectType    `gorm:"size:20;not null;index:idx_project_type"`
     Year               int            `gorm:"not null;default:2025"` // Default to current year
     Mentor             string         `gorm:"size:255"`              // Optional field
-	Contributors       []byte         `gorm:"type:jsonb;not null"`    // Changed to []byte
+    Contributors       []byte         `gorm:"type:jsonb;not null"`    // Free-text names of collaborators without an account
     Links              []byte         `gorm:"type:jsonb"`             // Changed to []byte
     PostedBy           string         `gorm:"size:36;not null;index:idx_project_owner"`
     Status             ProjectStatus  `gorm:"size:20;not null;default:'ACTIVE';index:idx_project_status"`
+    RepoProvider       string         `gorm:"size:20;not null;default:''"`  // Set from Links.CodeLink on save
+    RepoOwner          string         `gorm:"size:255;not null;default:''"`
+    RepoName           string         `gorm:"size:100;not null;default:''"`
+    MentorID           *string        `gorm:"size:36;index:idx_project_mentor"` // Faculty user linked as mentor
+    VerificationStatus ProjectVerificationStatus `gorm:"size:20;not null;default:'UNVERIFIED'"`
+    VerificationNote   string         `gorm:"type:text"`
+    VerifiedAt         *time.Time
     
     // Relationships
     User               User           `gorm:"foreignKey:PostedBy;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    MentorUser         *User          `gorm:"foreignKey:MentorID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+    Members            []ProjectMember `gorm:"foreignKey:ProjectID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    Media              []ProjectMedia  `gorm:"foreignKey:ProjectID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// RepositoryRef identifies a repository on a known code host.
+type RepositoryRef struct {
+    Provider string
+    Owner    string
+    Name     string
+}
+
+var repositoryHosts = map[string]string{
+    "github.com":    "github",
+    "gitlab.com":    "gitlab",
+    "bitbucket.org": "bitbucket",
+}
+
+var (
+    githubOwnerPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+    repoNamePattern    = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+    repoOwnerPattern   = regexp.MustCompile(`^[A-Za-z0-9._-]{1,255}$`)
+)
+
+// ParseRepositoryURL recognises GitHub, GitLab and Bitbucket repository URLs,
+// ignoring any trailing path such as /tree/main. ok is false for other hosts;
+// err is set when the host is recognised but the URL does not name a
+// repository.
+func ParseRepositoryURL(raw string) (ref RepositoryRef, ok bool, err error) {
+    u, parseErr := url.Parse(strings.TrimSpace(raw))
+    if parseErr != nil || (u.Scheme != "http" && u.Scheme != "https") {
+        return ref, false, nil
+    }
+    provider, known := repositoryHosts[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")]
+    if !known {
+        return ref, false, nil
+    }
+
+    segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+    if provider == "gitlab" {
+        // GitLab nests projects in subgroups; "/-/" starts the non-repo path
+        for i, segment := range segments {
+            if segment == "-" {
+                segments = segments[:i]
+                break
+            }
+        }
+    } else if len(segments) > 2 {
+        segments = segments[:2]
+    }
+    if len(segments) < 2 {
+        return ref, true, errors.New("URL must point to a repository, like https://" + u.Hostname() + "/owner/repo")
+    }
+
+    name := strings.TrimSuffix(segments[len(segments)-1], ".git")
+    owner := strings.Join(segments[:len(segments)-1], "/")
+    validOwner := true
+    for _, part := range segments[:len(segments)-1] {
+        if provider == "github" {
+            validOwner = validOwner && githubOwnerPattern.MatchString(part)
+        } else {
+            validOwner = validOwner && repoOwnerPattern.MatchString(part)
+        }
+    }
+    if !validOwner || !repoNamePattern.MatchString(name) || name == "." || name == ".." {
+        return ref, true, errors.New("URL does not name a valid repository")
+    }
+    return RepositoryRef{Provider: provider, Owner: owner, Name: name}, true, nil
+}
+
+// URL returns the canonical web address of the repository.
+func (r RepositoryRef) URL() string {
+    for host, provider := range repositoryHosts {
+        if provider == r.Provider {
+            return "https://" + host + "/" + r.Owner + "/" + r.Name
+        }
+    }
+    return ""
+}
+
+// BeforeSave keeps the repository columns in step with Links.CodeLink.
+func (p *Project) BeforeSave(tx *gorm.DB) error {
+    p.RepoProvider, p.RepoOwner, p.RepoName = "", "", ""
+    var links ProjectLinks
+    if len(p.Links) == 0 || json.Unmarshal(p.Links, &links) != nil {
+        return nil
+    }
+    if ref, ok, err := ParseRepositoryURL(links.CodeLink); ok && err == nil {
+        p.RepoProvider, p.RepoOwner, p.RepoName = ref.Provider, ref.Owner, ref.Name
+    }
+    return nil
+}
+
+// ProjectMedia is a screenshot in a project's gallery, stored under uploads.
+type ProjectMedia struct {
+    BaseModel  `gorm:"embedded"`
+    ProjectID  string `gorm:"size:36;not null;index:idx_project_media"`
+    Path       string `gorm:"size:255;not null"`
+    Caption    string `gorm:"size:255"`
+    Position   int    `gorm:"not null;default:0"`
+    UploadedBy string `gorm:"size:36;not null"`
+
+    // Relationships
+    Project    Project `gorm:"foreignKey:ProjectID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    Uploader   User    `gorm:"foreignKey:UploadedBy;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type ProjectMemberRole string
+
+const (
+    ProjectRoleMaintainer  ProjectMemberRole = "MAINTAINER" // Can invite and remove other members
+    ProjectRoleDeveloper   ProjectMemberRole = "DEVELOPER"
+    ProjectRoleDesigner    ProjectMemberRole = "DESIGNER"
+    ProjectRoleResearcher  ProjectMemberRole = "RESEARCHER"
+    ProjectRoleContributor ProjectMemberRole = "CONTRIBUTOR"
+)
+
+type ProjectMemberStatus string
+
+const (
+    ProjectMemberInvited  ProjectMemberStatus = "INVITED"
+    ProjectMemberAccepted ProjectMemberStatus = "ACCEPTED"
+    ProjectMemberDeclined ProjectMemberStatus = "DECLINED"
+)
+
+// ProjectMember links a GradSpace user to a project they contribute to. The
+// owner (Project.PostedBy) is not stored here.
+type ProjectMember struct {
+    BaseModel   `gorm:"embedded"`
+    ProjectID   string              `gorm:"size:36;not null;uniqueIndex:idx_project_member"`
+    UserID      string              `gorm:"size:36;not null;uniqueIndex:idx_project_member;index:idx_project_member_user"`
+    Role        ProjectMemberRole   `gorm:"size:20;not null;default:'CONTRIBUTOR'"`
+    Status      ProjectMemberStatus `gorm:"size:20;not null;default:'INVITED'"`
+    InvitedBy   string              `gorm:"size:36;not null"`
+    RespondedAt *time.Time
+
+    // Relationships
+    Project     Project `gorm:"foreignKey:ProjectID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    User        User    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    Inviter     User    `gorm:"foreignKey:InvitedBy;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ProjectStar is a user's upvote on a project.
+type ProjectStar struct {
+    BaseModel   `gorm:"embedded"`
+    ProjectID   string `gorm:"size:36;not null;uniqueIndex:idx_project_star"`
+    UserID      string `gorm:"size:36;not null;uniqueIndex:idx_project_star"`
+
+    // Relationships
+    Project     Project `gorm:"foreignKey:ProjectID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    User        User    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ProjectEndorsement vouches for a skill shown in a project. Skill is one of
+// the project's tags, as spelled on the project.
+type ProjectEndorsement struct {
+    BaseModel   `gorm:"embedded"`
+    ProjectID   string `gorm:"size:36;not null;uniqueIndex:idx_project_endorsement"`
+    UserID      string `gorm:"size:36;not null;uniqueIndex:idx_project_endorsement"`
+    Skill       string `gorm:"size:100;not null;uniqueIndex:idx_project_endorsement"`
+
+    // Relationships
+    Project     Project `gorm:"foreignKey:ProjectID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+    User        User    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
 type SavedProject struct {
@@ -332,8 +821,10 @@ func MigrateDB(db *gorm.DB) error {
 		&User{}, &RegisterRequest{}, &Verification{}, &UserProfile{}, 
 		&SocialLinks{}, &Experience{}, &Education{}, &Post{}, &Comment{}, 
 		&Like{}, &Follow{}, &Notification{}, &Conversation{}, &Message{},
-		&Company{}, &Job{}, &SavedJob{}, &JobReport{},&Event{}, &SavedEvent{},
-		&Project{}, &SavedProject{},
+		&Company{}, &CompanyFollow{}, &Job{}, &SavedJob{}, &JobReport{},&Event{}, &EventException{}, &SavedEvent{}, &EventRSVP{}, &CalendarFeed{}, &EventReminder{},
+		&Project{}, &SavedProject{}, &ProjectMember{}, &ProjectMedia{},
+		&ProjectStar{}, &ProjectEndorsement{},
+		&LoginThrottle{}, &LoginAudit{}, &RecoveryCode{}, &UserSession{},
 	)
 	if err != nil {
 		return err
@@ -343,20 +834,84 @@ func MigrateDB(db *gorm.DB) error {
 	db.Exec(`ALTER TABLE events ADD CONSTRAINT chk_event_type 
     CHECK (event_type IN ('CAMPUS_EVENT', 'ALUM_EVENT'))`)
 
+	db.Exec(`ALTER TABLE events ADD CONSTRAINT chk_event_status 
+		CHECK (status IN ('SCHEDULED', 'CANCELLED'))`)
+
+	db.Exec(`ALTER TABLE events ADD CONSTRAINT chk_event_approval 
+		CHECK (approval_status IN ('PENDING', 'APPROVED', 'REJECTED'))`)
+
 	db.Exec(`ALTER TABLE jobs ADD CONSTRAINT chk_job_type 
 		CHECK (job_type IN ('Part-Time', 'Full-Time', 'Internship', 'Freelance'))`)
 
 	db.Exec(`ALTER TABLE job_reports ADD CONSTRAINT chk_report_reason 
 		CHECK (reason IN ('Fake Job', 'Scam', 'Discriminatory Content', 'Incorrect Information'))`)
 
+	// Backfill normalized company names. Duplicates fail the unique index and
+	// stay NULL until an admin merges them.
+	var companies []Company
+	db.Where("normalized_name IS NULL").Find(&companies)
+	for i := range companies {
+		db.Save(&companies[i])
+	}
+
+	// Backfill normalized company names on experiences saved before they
+	// were tracked
+	var experiences []Experience
+	db.Where("normalized_company_name = '' AND company_name <> ''").Find(&experiences)
+	for i := range experiences {
+		db.Save(&experiences[i])
+	}
+
+	// Backfill repository details for projects saved before they were tracked
+	var projects []Project
+	db.Where("repo_provider = '' AND links->>'code_link' <> ''").Find(&projects)
+	for i := range projects {
+		db.Save(&projects[i])
+	}
+
+	// Deleting a company must not silently delete its jobs
+	db.Exec(`ALTER TABLE jobs DROP CONSTRAINT IF EXISTS fk_jobs_company`)
+	db.Exec(`ALTER TABLE jobs ADD CONSTRAINT fk_jobs_company 
+		FOREIGN KEY (company_id) REFERENCES companies(id) ON UPDATE CASCADE ON DELETE RESTRICT`)
+
+	db.Exec(`ALTER TABLE experiences ADD CONSTRAINT fk_experiences_company 
+		FOREIGN KEY (company_id) REFERENCES companies(id) ON UPDATE CASCADE ON DELETE SET NULL`)
+
+	db.Exec(`ALTER TABLE job_reports ADD CONSTRAINT chk_report_status 
+		CHECK (status IN ('PENDING', 'DISMISSED', 'ACTIONED'))`)
+
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_notification_user_created ON notifications (user_id, created_at DESC)")
 	
+	db.Exec(`ALTER TABLE event_rsvps ADD CONSTRAINT chk_rsvp_status 
+		CHECK (status IN ('GOING', 'WAITLISTED', 'CANCELLED'))`)
+
+	db.Exec(`ALTER TABLE event_reminders ADD CONSTRAINT chk_reminder_status 
+		CHECK (status IN ('PENDING', 'SENDING', 'SENT', 'SKIPPED'))`)
+
 	db.Exec(`ALTER TABLE projects ADD CONSTRAINT chk_project_type 
 		CHECK (project_type IN ('PERSONAL', 'GROUP', 'COLLEGE'))`)
 
 	db.Exec(`ALTER TABLE projects ADD CONSTRAINT chk_project_status 
 		CHECK (status IN ('ACTIVE', 'COMPLETED'))`)
 
+	db.Exec(`ALTER TABLE projects ADD CONSTRAINT chk_project_verification 
+		CHECK (verification_status IN ('UNVERIFIED', 'PENDING', 'VERIFIED', 'REJECTED'))`)
+
+	db.Exec(`ALTER TABLE project_members ADD CONSTRAINT chk_project_member_role 
+		CHECK (role IN ('MAINTAINER', 'DEVELOPER', 'DESIGNER', 'RESEARCHER', 'CONTRIBUTOR'))`)
+
+	db.Exec(`ALTER TABLE project_members ADD CONSTRAINT chk_project_member_status 
+		CHECK (status IN ('INVITED', 'ACCEPTED', 'DECLINED'))`)
+
+	db.Exec(`ALTER TABLE login_throttles ADD CONSTRAINT chk_login_throttle_scope 
+		CHECK (scope IN ('ACCOUNT', 'IP'))`)
+
+	db.Exec(`ALTER TABLE login_audits DROP CONSTRAINT IF EXISTS chk_login_audit_reason`)
+	db.Exec(`ALTER TABLE login_audits ADD CONSTRAINT chk_login_audit_reason 
+		CHECK (reason IN ('UNKNOWN_EMAIL', 'BAD_PASSWORD', 'BAD_2FA_CODE', 'THROTTLED', 'LOCKED'))`)
+
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_login_audit_created ON login_audits (created_at DESC)")
+
 	return nil
 }
 
@@ -376,3 +931,96 @@ func CleanupOldNotifications() error {
 	}
 	return nil
 }
+
+// CleanupOldLoginAudits drops failed login records older than 90 days.
+func CleanupOldLoginAudits() error {
+	threshold := time.Now().UTC().AddDate(0, 0, -90)
+	return Session.Db.Where("created_at < ?", threshold).Delete(&LoginAudit{}).Error
+}
+
+// RevokeUserSessions revokes every active session of a user except keepID,
+// which may be empty. It returns the revoked session IDs.
+func RevokeUserSessions(tx *gorm.DB, userID, keepID string) ([]string, error) {
+	var ids []string
+	if err := tx.Model(&UserSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+	err := tx.Model(&UserSession{}).Where("id IN ?", ids).Update("revoked_at", time.Now()).Error
+	return ids, err
+}
+
+// CleanupOldSessions drops sessions that expired or were revoked more than
+// 30 days ago.
+func CleanupOldSessions() error {
+	threshold := time.Now().UTC().AddDate(0, 0, -30)
+	return Session.Db.Where("expires_at < ? OR revoked_at < ?", threshold, threshold).Delete(&UserSession{}).Error
+}
+
+// ScheduleEventReminders queues the reminders for a user who saved or RSVPed
+// an event. Reminders whose send time has already passed are not queued.
+// Reminders that were skipped, e.g. because the user unsaved the event
+// earlier, are queued again; ones that were sent or are being sent are left
+// untouched so nothing is sent twice.
+func ScheduleEventReminders(tx *gorm.DB, eventID, userID string, start time.Time) error {
+	now := time.Now()
+	reminders := make([]EventReminder, 0, len(EventReminderOffsets))
+	for kind, lead := range EventReminderOffsets {
+		sendAt := start.Add(-lead)
+		if !sendAt.After(now) {
+			continue
+		}
+		reminders = append(reminders, EventReminder{
+			EventID: eventID,
+			UserID:  userID,
+			Kind:    kind,
+			SendAt:  sendAt,
+			Status:  ReminderStatusPending,
+		})
+	}
+	if len(reminders) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "event_id"}, {Name: "user_id"}, {Name: "kind"}},
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "event_reminders.status IN ?", Vars: []interface{}{
+				[]ReminderStatus{ReminderStatusPending, ReminderStatusSkipped},
+			}},
+		}},
+		DoUpdates: append(clause.AssignmentColumns([]string{"send_at", "updated_at"}),
+			clause.Assignments(map[string]interface{}{
+				"status":     ReminderStatusPending,
+				"claimed_at": nil,
+			})...),
+	}).Create(&reminders).Error
+}
+
+// RescheduleEventReminders moves reminders after an event's start time
+// changes or it is no longer cancelled. Skipped reminders are queued again
+// when their new send time is still ahead; the sender skips them again if the
+// user has lost interest.
+func RescheduleEventReminders(tx *gorm.DB, eventID string, start time.Time) error {
+	now := time.Now()
+	for kind, lead := range EventReminderOffsets {
+		sendAt := start.Add(-lead)
+		statuses := []ReminderStatus{ReminderStatusPending}
+		if sendAt.After(now) {
+			statuses = append(statuses, ReminderStatusSkipped)
+		}
+		if err := tx.Model(&EventReminder{}).
+			Where("event_id = ? AND kind = ? AND status IN ?", eventID, kind, statuses).
+			Updates(map[string]interface{}{
+				"status":     ReminderStatusPending,
+				"send_at":    sendAt,
+				"claimed_at": nil,
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gradspaceBK/config"
+
+	"gorm.io/gorm"
+)
+
+// RecurrenceTimeLayout is the UTC form of RRULE UNTIL values and occurrence IDs.
+const RecurrenceTimeLayout = "20060102T150405Z"
+
+const (
+	maxRecurrenceCount    = 500
+	maxRecurrenceInterval = 99
+	// Upper bound on generated dates, so an open-ended daily series stops
+	// after roughly 13 years
+	maxRecurrenceIterations = 5000
+)
+
+var recurrenceFrequencies = map[string]bool{
+	"DAILY":   true,
+	"WEEKLY":  true,
+	"MONTHLY": true,
+}
+
+// RecurrenceRule is the supported subset of an RFC 5545 RRULE: FREQ
+// (DAILY, WEEKLY or MONTHLY), INTERVAL, and either COUNT or UNTIL.
+type RecurrenceRule struct {
+	Frequency string
+	Interval  int
+	Count     int
+	Until     *time.Time
+}
+
+func ParseRecurrenceRule(rule string) (RecurrenceRule, error) {
+	r := RecurrenceRule{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return r, fmt.Errorf("invalid rule part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Frequency = strings.ToUpper(value)
+			if !recurrenceFrequencies[r.Frequency] {
+				return r, errors.New("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRecurrenceInterval {
+				return r, fmt.Errorf("INTERVAL must be between 1 and %d", maxRecurrenceInterval)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRecurrenceCount {
+				return r, fmt.Errorf("COUNT must be between 1 and %d", maxRecurrenceCount)
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseRecurrenceUntil(value)
+			if err != nil {
+				return r, errors.New("UNTIL must be a date (YYYYMMDD) or UTC time (YYYYMMDDTHHMMSSZ)")
+			}
+			r.Until = &until
+		default:
+			return r, fmt.Errorf("%s is not supported", strings.ToUpper(key))
+		}
+	}
+	if r.Frequency == "" {
+		return r, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return r, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	return r, nil
+}
+
+// parseRecurrenceUntil treats a bare date as the end of that day in the
+// event time zone.
+func parseRecurrenceUntil(value string) (time.Time, error) {
+	if until, err := time.Parse(RecurrenceTimeLayout, value); err == nil {
+		return until, nil
+	}
+	day, err := time.ParseInLocation("20060102", value, config.GetEventTimezone())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.Add(24*time.Hour - time.Second).UTC(), nil
+}
+
+// String renders the rule in canonical RRULE form, without the "RRULE:" prefix.
+func (r RecurrenceRule) String() string {
+	parts := []string{"FREQ=" + r.Frequency}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(RecurrenceTimeLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns the start times of occurrences that start between from
+// and to, inclusive. Dates are stepped in the event time zone so a series
+// keeps its wall-clock time; monthly series skip months that lack the day.
+func (r RecurrenceRule) Occurrences(start, from, to time.Time) []time.Time {
+	loc := config.GetEventTimezone()
+	local := start.In(loc)
+
+	var result []time.Time
+	generated := 0
+	for i := 0; i < maxRecurrenceIterations; i++ {
+		var candidate time.Time
+		switch r.Frequency {
+		case "DAILY":
+			candidate = local.AddDate(0, 0, i*r.Interval)
+		case "WEEKLY":
+			candidate = local.AddDate(0, 0, 7*i*r.Interval)
+		case "MONTHLY":
+			candidate = time.Date(local.Year(), local.Month()+time.Month(i*r.Interval), local.Day(),
+				local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), loc)
+			if candidate.Day() != local.Day() {
+				continue
+			}
+		}
+
+		if r.Until != nil && candidate.After(*r.Until) {
+			break
+		}
+		generated++
+		if r.Count > 0 && generated > r.Count {
+			break
+		}
+		if candidate.After(to) {
+			break
+		}
+		if !candidate.Before(from) {
+			result = append(result, candidate.UTC())
+		}
+	}
+	return result
+}
+
+// IsOccurrence reports whether the series starting at start has an
+// occurrence at t.
+func (r RecurrenceRule) IsOccurrence(start, t time.Time) bool {
+	return len(r.Occurrences(start, t, t)) == 1
+}
+
+// LastOccurrence returns the start of the final occurrence, or nil for a
+// series without COUNT or UNTIL.
+func (r RecurrenceRule) LastOccurrence(start time.Time) *time.Time {
+	if r.Count == 0 && r.Until == nil {
+		return nil
+	}
+	all := r.Occurrences(start, start, start.AddDate(100, 0, 0))
+	if len(all) == 0 {
+		return nil
+	}
+	last := all[len(all)-1]
+	return &last
+}
+
+// LastEndDateTime returns when an event's final occurrence ends, or nil for a
+// series without an end.
+func (e Event) LastEndDateTime() *time.Time {
+	if e.RecurrenceRule == nil {
+		end := e.EndDateTime
+		return &end
+	}
+	if e.RecurrenceEnd == nil {
+		return nil
+	}
+	end := e.RecurrenceEnd.Add(e.EndDateTime.Sub(e.StartDateTime))
+	return &end
+}
+
+// HasEnded reports whether every occurrence of an event is over.
+func (e Event) HasEnded(now time.Time) bool {
+	end := e.LastEndDateTime()
+	return end != nil && !end.After(now)
+}
+
+// NextEventOccurrence returns the start of the first occurrence of an event
+// after the given time. Removed occurrences and ones that were edited into
+// their own event are passed over. ok is false when there is none.
+func NextEventOccurrence(tx *gorm.DB, event Event, after time.Time) (start time.Time, ok bool, err error) {
+	if event.RecurrenceRule == nil {
+		return event.StartDateTime, event.StartDateTime.After(after), nil
+	}
+	rule, err := ParseRecurrenceRule(*event.RecurrenceRule)
+	if err != nil {
+		return start, false, err
+	}
+
+	var skipped []time.Time
+	if err := tx.Model(&EventException{}).Where("event_id = ?", event.ID).
+		Pluck("occurrence_start", &skipped).Error; err != nil {
+		return start, false, err
+	}
+	var edited []time.Time
+	if err := tx.Model(&Event{}).Where("series_id = ?", event.ID).
+		Pluck("occurrence_start", &edited).Error; err != nil {
+		return start, false, err
+	}
+	skip := make(map[int64]bool, len(skipped)+len(edited))
+	for _, t := range append(skipped, edited...) {
+		skip[t.Unix()] = true
+	}
+
+	// Look a year ahead at a time rather than expanding the whole series
+	from := after.Add(time.Nanosecond)
+	for year := 0; year < 15; year++ {
+		to := from.AddDate(1, 0, 0)
+		for _, candidate := range rule.Occurrences(event.StartDateTime, from, to) {
+			if !skip[candidate.Unix()] {
+				return candidate, true, nil
+			}
+		}
+		if event.RecurrenceEnd != nil && event.RecurrenceEnd.Before(to) {
+			break
+		}
+		from = to.Add(time.Nanosecond)
+	}
+	return start, false, nil
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseRule(t *testing.T, rule string) RecurrenceRule {
+	t.Helper()
+	r, err := ParseRecurrenceRule(rule)
+	if err != nil {
+		t.Fatalf("ParseRecurrenceRule(%q): %v", rule, err)
+	}
+	return r
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	t.Setenv("EVENT_TIMEZONE", "UTC")
+
+	tests := []struct {
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		{rule: "FREQ=WEEKLY", want: "FREQ=WEEKLY"},
+		{rule: "RRULE:freq=daily;interval=2;count=5", want: "FREQ=DAILY;INTERVAL=2;COUNT=5"},
+		{rule: "FREQ=MONTHLY;INTERVAL=1", want: "FREQ=MONTHLY"},
+		{rule: "FREQ=WEEKLY;UNTIL=20250301T000000Z", want: "FREQ=WEEKLY;UNTIL=20250301T000000Z"},
+		{rule: "FREQ=DAILY;UNTIL=20250301", want: "FREQ=DAILY;UNTIL=20250301T235959Z"},
+		{rule: "", wantErr: true},
+		{rule: "INTERVAL=2", wantErr: true},
+		{rule: "FREQ=YEARLY", wantErr: true},
+		{rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{rule: "FREQ=DAILY;INTERVAL=100", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=0", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=501", wantErr: true},
+		{rule: "FREQ=DAILY;UNTIL=tomorrow", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=3;UNTIL=20250301", wantErr: true},
+		{rule: "FREQ=WEEKLY;BYDAY=MO", wantErr: true},
+		{rule: "FREQ=WEEKLY;COUNT", wantErr: true},
+	}
+	for _, tc := range tests {
+		r, err := ParseRecurrenceRule(tc.rule)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseRecurrenceRule(%q) = %s, want error", tc.rule, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRecurrenceRule(%q): %v", tc.rule, err)
+			continue
+		}
+		if got := r.String(); got != tc.want {
+			t.Errorf("ParseRecurrenceRule(%q) = %s, want %s", tc.rule, got, tc.want)
+		}
+	}
+}
+
+// A weekly series keeps its wall-clock time when the event time zone changes
+// between standard and daylight time, so its UTC start shifts by an hour.
+func TestOccurrencesAcrossDST(t *testing.T) {
+	t.Setenv("EVENT_TIMEZONE", "America/New_York")
+	loc := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name  string
+		start time.Time
+		want  []string
+	}{
+		{
+			name:  "spring forward",
+			start: time.Date(2025, time.March, 2, 18, 0, 0, 0, loc),
+			want: []string{
+				"2025-03-02T23:00:00Z",
+				"2025-03-09T22:00:00Z",
+				"2025-03-16T22:00:00Z",
+			},
+		},
+		{
+			name:  "fall back",
+			start: time.Date(2025, time.October, 26, 9, 30, 0, 0, loc),
+			want: []string{
+				"2025-10-26T13:30:00Z",
+				"2025-11-02T14:30:00Z",
+				"2025-11-09T14:30:00Z",
+			},
+		},
+	}
+	rule := mustParseRule(t, "FREQ=WEEKLY;COUNT=3")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := rule.Occurrences(tc.start.UTC(), tc.start, tc.start.AddDate(1, 0, 0))
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d occurrences %v, want %v", len(got), got, tc.want)
+			}
+			for i, occurrence := range got {
+				if s := occurrence.Format(time.RFC3339); s != tc.want[i] {
+					t.Errorf("occurrence %d = %s, want %s", i, s, tc.want[i])
+				}
+				local := occurrence.In(loc)
+				if local.Hour() != tc.start.Hour() || local.Minute() != tc.start.Minute() {
+					t.Errorf("occurrence %d is at %s local time, want %s",
+						i, local.Format("15:04"), tc.start.Format("15:04"))
+				}
+			}
+		})
+	}
+}
+
+// Monthly series starting late in the month skip months without that day
+// rather than moving to the last day or spilling into the next month.
+func TestOccurrencesMonthlyEndOfMonth(t *testing.T) {
+	t.Setenv("EVENT_TIMEZONE", "UTC")
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []string
+	}{
+		{
+			name:  "31st",
+			rule:  "FREQ=MONTHLY;COUNT=4",
+			start: time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-31", "2025-03-31", "2025-05-31", "2025-07-31"},
+		},
+		{
+			name:  "30th",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: time.Date(2025, time.January, 30, 10, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-30", "2025-03-30", "2025-04-30"},
+		},
+		{
+			name:  "29th of February",
+			rule:  "FREQ=MONTHLY;INTERVAL=12;COUNT=2",
+			start: time.Date(2024, time.February, 29, 10, 0, 0, 0, time.UTC),
+			want:  []string{"2024-02-29", "2028-02-29"},
+		},
+		{
+			name:  "31st every other month",
+			rule:  "FREQ=MONTHLY;INTERVAL=2;UNTIL=20251231T235959Z",
+			start: time.Date(2025, time.August, 31, 10, 0, 0, 0, time.UTC),
+			want:  []string{"2025-08-31", "2025-10-31", "2025-12-31"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := mustParseRule(t, tc.rule)
+			got := rule.Occurrences(tc.start, tc.start, tc.start.AddDate(10, 0, 0))
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d occurrences %v, want %v", len(got), got, tc.want)
+			}
+			for i, occurrence := range got {
+				if d := occurrence.Format("2006-01-02"); d != tc.want[i] {
+					t.Errorf("occurrence %d = %s, want %s", i, d, tc.want[i])
+				}
+				if occurrence.Hour() != 10 {
+					t.Errorf("occurrence %d at %s, want 10:00", i, occurrence.Format("15:04"))
+				}
+			}
+		})
+	}
+}
+
+func TestOccurrencesWindow(t *testing.T) {
+	t.Setenv("EVENT_TIMEZONE", "UTC")
+	start := time.Date(2025, time.June, 1, 9, 0, 0, 0, time.UTC)
+	rule := mustParseRule(t, "FREQ=DAILY;INTERVAL=2")
+
+	got := rule.Occurrences(start, start.AddDate(0, 0, 3), start.AddDate(0, 0, 8))
+	want := []string{"2025-06-05", "2025-06-07", "2025-06-09"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, occurrence := range got {
+		if d := occurrence.Format("2006-01-02"); d != want[i] {
+			t.Errorf("occurrence %d = %s, want %s", i, d, want[i])
+		}
+	}
+
+	if !rule.IsOccurrence(start, start.AddDate(0, 0, 4)) {
+		t.Error("IsOccurrence rejected the third occurrence")
+	}
+	if rule.IsOccurrence(start, start.AddDate(0, 0, 3)) {
+		t.Error("IsOccurrence accepted a day between occurrences")
+	}
+	if rule.IsOccurrence(start, start.AddDate(0, 0, 4).Add(time.Hour)) {
+		t.Error("IsOccurrence accepted the wrong time of day")
+	}
+}
+
+func TestLastOccurrence(t *testing.T) {
+	t.Setenv("EVENT_TIMEZONE", "UTC")
+	start := time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"FREQ=WEEKLY", ""},
+		{"FREQ=WEEKLY;COUNT=1", "2025-01-31T10:00:00Z"},
+		{"FREQ=WEEKLY;COUNT=3", "2025-02-14T10:00:00Z"},
+		{"FREQ=MONTHLY;COUNT=3", "2025-05-31T10:00:00Z"},
+		{"FREQ=DAILY;UNTIL=20250203T100000Z", "2025-02-03T10:00:00Z"},
+		{"FREQ=DAILY;UNTIL=20250203T095959Z", "2025-02-02T10:00:00Z"},
+		{"FREQ=DAILY;UNTIL=20250130T000000Z", ""},
+	}
+	for _, tc := range tests {
+		last := mustParseRule(t, tc.rule).LastOccurrence(start)
+		got := ""
+		if last != nil {
+			got = last.Format(time.RFC3339)
+		}
+		if got != tc.want {
+			t.Errorf("LastOccurrence(%s) = %q, want %q", tc.rule, got, tc.want)
+		}
+	}
+}
+
+func TestEventHasEnded(t *testing.T) {
+	start := time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC)
+	lastStart := start.AddDate(0, 0, 14)
+	rule := "FREQ=WEEKLY;COUNT=3"
+
+	single := Event{StartDateTime: start, EndDateTime: start.Add(2 * time.Hour)}
+	series := Event{
+		StartDateTime:  start,
+		EndDateTime:    start.Add(2 * time.Hour),
+		RecurrenceRule: &rule,
+		RecurrenceEnd:  &lastStart,
+	}
+	openRule := "FREQ=WEEKLY"
+	open := Event{StartDateTime: start, EndDateTime: start.Add(2 * time.Hour), RecurrenceRule: &openRule}
+
+	tests := []struct {
+		name  string
+		event Event
+		now   time.Time
+		want  bool
+	}{
+		{"single before end", single, start.Add(time.Hour), false},
+		{"single at end", single, start.Add(2 * time.Hour), true},
+		{"series after first occurrence", series, start.AddDate(0, 0, 1), false},
+		{"series during last occurrence", series, lastStart.Add(time.Hour), false},
+		{"series after last occurrence", series, lastStart.Add(3 * time.Hour), true},
+		{"open-ended series", open, start.AddDate(20, 0, 0), false},
+	}
+	for _, tc := range tests {
+		if got := tc.event.HasEnded(tc.now); got != tc.want {
+			t.Errorf("%s: HasEnded = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	"gradspaceBK/config"
 	"gradspaceBK/controller"
 	"gradspaceBK/database"
+	"gradspaceBK/services"
+	"gradspaceBK/util"
 	"gradspaceBK/ws"
 
 	"github.com/gofiber/fiber/v2"
@@ -27,6 +30,9 @@ func main() {
 
 func RunServer() {
 	config.LoadConfig() // Load .env first
+	if err := util.LoadKeyring(); err != nil {
+		log.Fatal(err)
+	}
 	database.DBConnection()
 
 	// Start cleanup job
@@ -43,10 +49,41 @@ func RunServer() {
 			if err := database.CleanupOldNotifications(); err != nil {
 				fmt.Printf("Notification cleanup error: %v\n", err)
 			}
+			if err := database.CleanupOldLoginAudits(); err != nil {
+				fmt.Printf("Login audit cleanup error: %v\n", err)
+			}
+			if err := database.CleanupOldSessions(); err != nil {
+				fmt.Printf("Session cleanup error: %v\n", err)
+			}
+			if err := services.ProcessDueAccountDeletions(); err != nil {
+				fmt.Printf("Account deletion error: %v\n", err)
+			}
+		}
+	}()
+
+	// Event reminders; safe to run on every instance
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := services.ProcessDueEventReminders(); err != nil {
+				fmt.Printf("Event reminder error: %v\n", err)
+			}
 		}
 	}()
 
-	app := fiber.New()
+	// Behind a reverse proxy the client's address comes from its header, so
+	// login throttling and rate limits apply per client rather than to the
+	// proxy as a whole
+	appConfig := fiber.Config{}
+	if proxyHeader, proxies := config.GetProxyConfig(); len(proxies) > 0 {
+		appConfig.ProxyHeader = proxyHeader
+		appConfig.EnableTrustedProxyCheck = true
+		appConfig.TrustedProxies = proxies
+		appConfig.EnableIPValidation = true
+	}
+	app := fiber.New(appConfig)
 	// Static files and logger
 	app.Static("/api/v1/uploads", "./uploads")
 	app.Use(logger.New())
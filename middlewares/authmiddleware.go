@@ -19,6 +19,26 @@ func AuthMiddleware(c *fiber.Ctx) error {
 			"message": "Unauthorized",
 		})
 	}
-	c.Locals("user_data", claim)
+
+	// Refresh tokens live longer and must not be usable as access tokens
+	if claim.Type != util.TokenTypeAccess {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Unauthorized",
+		})
+	}
+
+	// Tokens must belong to a live session, so revoked logins stop working
+	active, err := sessionActive(claim.SessionID, claim.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Internal Server Error",
+		})
+	}
+	if !active {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Unauthorized",
+		})
+	}
+	c.Locals(claimsLocal, claim)
 	return c.Next()
 }
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"errors"
+
+	"gradspaceBK/database"
+	"gradspaceBK/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Keys AuthMiddleware and CurrentUser store their results under in c.Locals.
+const (
+	claimsLocal      = "user_data"
+	currentUserLocal = "current_user"
+)
+
+// ErrNotAuthenticated is returned by CurrentUser on routes AuthMiddleware
+// has not run for.
+var ErrNotAuthenticated = errors.New("request is not authenticated")
+
+// AuthClaims returns the access token claims AuthMiddleware verified for this
+// request.
+func AuthClaims(c *fiber.Ctx) (*util.Claims, bool) {
+	claims, ok := c.Locals(claimsLocal).(*util.Claims)
+	return claims, ok && claims != nil
+}
+
+// AuthUserID returns the ID of the authenticated user, or "" when the request
+// is not authenticated.
+func AuthUserID(c *fiber.Ctx) string {
+	if claims, ok := AuthClaims(c); ok {
+		return claims.UserID
+	}
+	return ""
+}
+
+// CurrentUser returns the authenticated user. It is loaded from the database
+// on first use and reused for the rest of the request, so handlers and
+// middlewares can all call it freely.
+func CurrentUser(c *fiber.Ctx) (*database.User, error) {
+	if user, ok := c.Locals(currentUserLocal).(*database.User); ok {
+		return user, nil
+	}
+	userID := AuthUserID(c)
+	if userID == "" {
+		return nil, ErrNotAuthenticated
+	}
+	var user database.User
+	if err := database.Session.Db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	c.Locals(currentUserLocal, &user)
+	return &user, nil
+}
@@ -0,0 +1,181 @@
+package middlewares
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gradspaceBK/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Limit allows Count requests per Period, with bursts of up to Count. A zero
+// Limit disables that bucket.
+type Limit struct {
+	Count  int
+	Period time.Duration
+}
+
+// RateLimitResult is the state of a bucket after taking a token from it.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // Until the next token, when not allowed
+	ResetAfter time.Duration // Until the bucket is full again
+}
+
+// RateLimitStore holds token buckets. The in-memory store only limits a
+// single instance; a shared store lets several instances enforce one limit.
+type RateLimitStore interface {
+	Take(key string, limit Limit, now time.Time) (RateLimitResult, error)
+}
+
+var rateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+// SetRateLimitStore replaces the store used by RateLimit. Call it before the
+// routes are set up.
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitStore = store
+}
+
+// RateLimit limits a route with one token bucket per user and one per client
+// IP. The user bucket is only used after AuthMiddleware. Defaults can be
+// overridden with RATE_LIMIT_<NAME>_USER and RATE_LIMIT_<NAME>_IP, e.g.
+// RATE_LIMIT_CREATE_POST_USER=5/1m.
+func RateLimit(name string, perUser, perIP Limit) fiber.Handler {
+	if !config.RateLimitEnabled() {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	envName := "RATE_LIMIT_" + strings.ToUpper(name)
+	perUser = configuredLimit(envName+"_USER", perUser)
+	perIP = configuredLimit(envName+"_IP", perIP)
+
+	return func(c *fiber.Ctx) error {
+		var buckets []limitedBucket
+		if perUser.Count > 0 {
+			if userID := AuthUserID(c); userID != "" {
+				buckets = append(buckets, limitedBucket{name + ":user:" + userID, perUser})
+			}
+		}
+		if perIP.Count > 0 {
+			buckets = append(buckets, limitedBucket{name + ":ip:" + c.IP(), perIP})
+		}
+
+		// Report the bucket closest to running out
+		var tightest *RateLimitResult
+		var tightestLimit Limit
+		now := time.Now()
+		for _, bucket := range buckets {
+			result, err := rateLimitStore.Take(bucket.key, bucket.limit, now)
+			if err != nil {
+				// Fail open so an unavailable store doesn't take the route down
+				log.Printf("Rate limit store error for %s: %v", bucket.key, err)
+				continue
+			}
+			if !result.Allowed {
+				setRateLimitHeaders(c, bucket.limit, result)
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+					"success":     false,
+					"message":     "Too many requests. Please slow down.",
+					"retry_after": ceilSeconds(result.RetryAfter),
+				})
+			}
+			if tightest == nil || result.Remaining < tightest.Remaining {
+				tightest = &result
+				tightestLimit = bucket.limit
+			}
+		}
+		if tightest != nil {
+			setRateLimitHeaders(c, tightestLimit, *tightest)
+		}
+		return c.Next()
+	}
+}
+
+type limitedBucket struct {
+	key   string
+	limit Limit
+}
+
+func configuredLimit(envName string, fallback Limit) Limit {
+	if count, period, ok := config.GetRateLimit(envName); ok {
+		return Limit{Count: count, Period: period}
+	}
+	return fallback
+}
+
+func setRateLimitHeaders(c *fiber.Ctx, limit Limit, result RateLimitResult) {
+	c.Set("X-RateLimit-Limit", strconv.Itoa(limit.Count))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // When the bucket refills completely; idle after that
+}
+
+// MemoryRateLimitStore keeps buckets in process memory.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit Limit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	capacity := float64(limit.Count)
+	perToken := limit.Period / time.Duration(limit.Count)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		s.buckets[key] = bucket
+	}
+	elapsed := now.Sub(bucket.last)
+	bucket.tokens = math.Min(capacity, bucket.tokens+float64(elapsed)/float64(perToken))
+	bucket.last = now
+
+	result := RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) * float64(perToken))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.ResetAfter = time.Duration((capacity - bucket.tokens) * float64(perToken))
+	bucket.full = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// sweep drops full buckets once a minute; a missing bucket starts out full.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
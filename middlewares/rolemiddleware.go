@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"slices"
+
+	"gradspaceBK/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HasRole reports whether the user holds one of roles. Admins only count as
+// admins once they have two-factor authentication on, so handlers that check
+// roles themselves follow the same rule as RequireRoles.
+func HasRole(user *database.User, roles ...string) bool {
+	if !slices.Contains(roles, user.Role) {
+		return false
+	}
+	return user.Role != "Admin" || user.TwoFactorEnabled
+}
+
+// RequireRoles only lets through users whose role is one of roles. It must be
+// mounted after AuthMiddleware, and stores the role in c.Locals("user_role").
+// Admins are only let through once they have two-factor authentication on.
+func RequireRoles(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := CurrentUser(c)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Unauthorized",
+			})
+		}
+
+		if HasRole(user, roles...) {
+			c.Locals("user_role", user.Role)
+			return c.Next()
+		}
+		if user.Role == "Admin" && slices.Contains(roles, user.Role) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message":                   "Two-factor authentication is required for admin accounts",
+				"two_factor_setup_required": true,
+			})
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden",
+		})
+	}
+}
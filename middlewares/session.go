@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"sync"
+	"time"
+
+	"gradspaceBK/database"
+)
+
+// Session lookups are cached briefly so AuthMiddleware doesn't hit the
+// database on every request. A revocation made through ForgetSession takes
+// effect at once on this instance and within sessionCacheTTL elsewhere.
+const (
+	sessionCacheTTL = 30 * time.Second
+	// last_used_at is only written when it is older than this
+	sessionTouchInterval = 5 * time.Minute
+)
+
+type cachedSession struct {
+	userID    string
+	active    bool
+	checkedAt time.Time
+}
+
+var (
+	sessionCacheMu   sync.Mutex
+	sessionCache     = make(map[string]cachedSession)
+	sessionLastSweep time.Time
+)
+
+// sessionActive reports whether a session exists, belongs to userID and has
+// not been revoked or expired.
+func sessionActive(sessionID, userID string) (bool, error) {
+	now := time.Now()
+
+	sessionCacheMu.Lock()
+	cached, ok := sessionCache[sessionID]
+	sessionCacheMu.Unlock()
+	if ok && now.Sub(cached.checkedAt) < sessionCacheTTL {
+		return cached.active && cached.userID == userID, nil
+	}
+
+	var session database.UserSession
+	result := database.Session.Db.Where("id = ?", sessionID).Limit(1).Find(&session)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	active := result.RowsAffected == 1 && session.RevokedAt == nil && now.Before(session.ExpiresAt)
+	if active && now.Sub(session.LastUsedAt) > sessionTouchInterval {
+		database.Session.Db.Model(&database.UserSession{}).
+			Where("id = ?", sessionID).
+			Update("last_used_at", now)
+	}
+
+	sessionCacheMu.Lock()
+	defer sessionCacheMu.Unlock()
+	if now.Sub(sessionLastSweep) > time.Minute {
+		sessionLastSweep = now
+		for id, entry := range sessionCache {
+			if now.Sub(entry.checkedAt) >= sessionCacheTTL {
+				delete(sessionCache, id)
+			}
+		}
+	}
+	sessionCache[sessionID] = cachedSession{userID: session.UserID, active: active, checkedAt: now}
+	return active && session.UserID == userID, nil
+}
+
+// ForgetSession drops cached state for revoked sessions so this instance
+// refuses them immediately.
+func ForgetSession(sessionIDs ...string) {
+	sessionCacheMu.Lock()
+	defer sessionCacheMu.Unlock()
+	for _, id := range sessionIDs {
+		delete(sessionCache, id)
+	}
+}
+
+// ForgetUserSessions is ForgetSession for every cached session of a user.
+func ForgetUserSessions(userID string) {
+	sessionCacheMu.Lock()
+	defer sessionCacheMu.Unlock()
+	for id, entry := range sessionCache {
+		if entry.userID == userID {
+			delete(sessionCache, id)
+		}
+	}
+}
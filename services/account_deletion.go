@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gradspaceBK/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProcessDueAccountDeletions erases every account whose deletion grace period
+// has passed. Each account is locked while it is erased, so a login that
+// cancels the deletion at the same moment either wins or waits, and several
+// instances can run this at once.
+func ProcessDueAccountDeletions() error {
+	session := database.Session.Db
+
+	var ids []string
+	if err := session.Model(&database.User{}).
+		Where("deletion_due_at <= ? AND anonymized_at IS NULL", time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := eraseAccount(session, id); err != nil {
+			fmt.Printf("Account deletion %s failed: %v\n", id, err)
+		}
+	}
+	return nil
+}
+
+func eraseAccount(session *gorm.DB, userID string) error {
+	var paths []string
+	err := session.Transaction(func(tx *gorm.DB) error {
+		var user database.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND deletion_due_at <= ? AND anonymized_at IS NULL", userID, time.Now()).
+			First(&user).Error; err != nil {
+			return err
+		}
+		var err error
+		if paths, err = database.UserUploadPaths(tx, userID); err != nil {
+			return err
+		}
+		return database.EraseUser(tx, userID)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Cancelled, or taken by another instance
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Removing %s for deleted account %s failed: %v\n", path, userID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gradspaceBK/config"
+	"gradspaceBK/database"
+	"gradspaceBK/util"
+
+	"gorm.io/gorm"
+)
+
+// Reminders claimed per pass; the rest wait for the next tick.
+const reminderBatchSize = 100
+
+// ProcessDueEventReminders sends every pending reminder whose time has come.
+//
+// Reminders are claimed with FOR UPDATE SKIP LOCKED, so several server
+// instances can run this concurrently without picking the same row. A claimed
+// reminder is never claimed again: if the process dies mid-send the reminder
+// is lost rather than sent twice.
+func ProcessDueEventReminders() error {
+	session := database.Session.Db
+
+	for {
+		ids, err := claimDueReminders(session)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		var reminders []database.EventReminder
+		if err := session.Preload("Event").Preload("User").
+			Where("id IN ?", ids).
+			Find(&reminders).Error; err != nil {
+			return err
+		}
+		for _, reminder := range reminders {
+			if err := deliverReminder(session, reminder); err != nil {
+				fmt.Printf("Event reminder %s failed: %v\n", reminder.ID, err)
+			}
+		}
+
+		if len(ids) < reminderBatchSize {
+			return nil
+		}
+	}
+}
+
+func claimDueReminders(session *gorm.DB) ([]string, error) {
+	now := time.Now()
+	var ids []string
+	err := session.Raw(`
+		UPDATE event_reminders SET status = ?, claimed_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM event_reminders
+			WHERE status = ? AND send_at <= ?
+			ORDER BY send_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`,
+		database.ReminderStatusSending, now, now,
+		database.ReminderStatusPending, now, reminderBatchSize,
+	).Scan(&ids).Error
+	return ids, err
+}
+
+// deliverReminder re-checks a claimed reminder against the current state of
+// the event before sending, since the event may have moved or the user may
+// have unsaved it since the reminder was scheduled. Reminders for a series
+// are for its next occurrence, and are queued again for the one after.
+func deliverReminder(session *gorm.DB, reminder database.EventReminder) error {
+	now := time.Now()
+	event := reminder.Event
+	lead, known := database.EventReminderOffsets[reminder.Kind]
+	if !known || event.Status == database.EventStatusCancelled {
+		return markReminder(session, reminder, database.ReminderStatusSkipped)
+	}
+	start, ok, err := database.NextEventOccurrence(session, event, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return markReminder(session, reminder, database.ReminderStatusSkipped)
+	}
+
+	// The event was moved later: put the reminder back in the queue
+	if sendAt := start.Add(-lead); sendAt.After(now) {
+		return requeueReminder(session, reminder, sendAt)
+	}
+
+	// After downtime, only the closest reminder is worth sending
+	for _, other := range database.EventReminderOffsets {
+		if other < lead && !start.Add(-other).After(now) {
+			return finishReminder(session, reminder, event, start, lead, database.ReminderStatusSkipped)
+		}
+	}
+
+	interested, err := isInterestedInEvent(session, reminder.UserID, event.ID)
+	if err != nil {
+		return err
+	}
+	if !interested {
+		return markReminder(session, reminder, database.ReminderStatusSkipped)
+	}
+
+	startsIn := "in " + humanizeLead(start.Sub(now))
+	notification := database.Notification{
+		UserID:    reminder.UserID,
+		CreatorID: event.PostedBy,
+		Type:      database.NotificationTypeEventReminder,
+		EventID:   &event.ID,
+		Message:   fmt.Sprintf("\"%s\" starts %s at %s.", event.Title, startsIn, event.Venue),
+	}
+	if err := session.Create(&notification).Error; err != nil {
+		return err
+	}
+	if err := finishReminder(session, reminder, event, start, lead, database.ReminderStatusSent); err != nil {
+		return err
+	}
+
+	return sendReminderEmail(reminder.User, event, start, startsIn)
+}
+
+// finishReminder records what happened to a reminder for the occurrence at
+// start. For a series it is then queued for the following occurrence.
+func finishReminder(session *gorm.DB, reminder database.EventReminder, event database.Event, start time.Time, lead time.Duration, status database.ReminderStatus) error {
+	if event.RecurrenceRule == nil {
+		return markReminder(session, reminder, status)
+	}
+	next, ok, err := database.NextEventOccurrence(session, event, start)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return markReminder(session, reminder, status)
+	}
+	if status == database.ReminderStatusSent {
+		if err := session.Model(&reminder).Update("sent_at", time.Now()).Error; err != nil {
+			return err
+		}
+	}
+	return requeueReminder(session, reminder, next.Add(-lead))
+}
+
+func requeueReminder(session *gorm.DB, reminder database.EventReminder, sendAt time.Time) error {
+	return session.Model(&reminder).Updates(map[string]interface{}{
+		"status":     database.ReminderStatusPending,
+		"send_at":    sendAt,
+		"claimed_at": nil,
+	}).Error
+}
+
+func sendReminderEmail(user database.User, event database.Event, start time.Time, startsIn string) error {
+	data := map[string]interface{}{
+		"userName":   user.FullName,
+		"eventTitle": event.Title,
+		"startsIn":   startsIn,
+		"startTime":  start.In(config.GetEventTimezone()).Format("Mon, 02 Jan 2006 at 3:04 PM MST"),
+		"venue":      event.Venue,
+	}
+	html, err := util.RenderTemplate("templates/event_reminder_email.html", data)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Reminder: %s starts %s", event.Title, startsIn)
+	text := fmt.Sprintf("Hello %s, %s starts %s at %s.", user.FullName, event.Title, startsIn, event.Venue)
+	return SendEmail(user.Email, subject, text, html)
+}
+
+// isInterestedInEvent reports whether the user still has the event saved or
+// holds an active RSVP for it.
+func isInterestedInEvent(session *gorm.DB, userID, eventID string) (bool, error) {
+	var saved int64
+	if err := session.Model(&database.SavedEvent{}).
+		Where("user_id = ? AND event_id = ?", userID, eventID).
+		Count(&saved).Error; err != nil {
+		return false, err
+	}
+	if saved > 0 {
+		return true, nil
+	}
+
+	var rsvps int64
+	err := session.Model(&database.EventRSVP{}).
+		Where("user_id = ? AND event_id = ? AND status <> ?", userID, eventID, database.RSVPStatusCancelled).
+		Count(&rsvps).Error
+	return rsvps > 0, err
+}
+
+func markReminder(session *gorm.DB, reminder database.EventReminder, status database.ReminderStatus) error {
+	updates := map[string]interface{}{"status": status}
+	if status == database.ReminderStatusSent {
+		updates["sent_at"] = time.Now()
+	}
+	return session.Model(&reminder).Updates(updates).Error
+}
+
+// humanizeLead rounds the time left to whole hours, or minutes under an hour.
+func humanizeLead(d time.Duration) string {
+	if d < time.Hour {
+		minutes := int(d.Round(time.Minute).Minutes())
+		if minutes <= 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	hours := int(d.Round(time.Hour).Hours())
+	if hours == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", hours)
+}
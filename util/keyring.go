@@ -0,0 +1,266 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"gradspaceBK/config"
+)
+
+// Tokens are signed with the keyring's active key and carry its ID in the
+// "kid" header, so keys can be rotated without logging everyone out: add the
+// new key, make it active, and retire the old one once its tokens expire.
+
+// SigningKey is a key in the token keyring.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Retired bool
+
+	signKey   interface{} // nil when only the public key is known
+	verifyKey interface{}
+}
+
+// Keyring holds every configured token key.
+type Keyring struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+var (
+	keyringMu     sync.Mutex
+	activeKeyring *Keyring
+)
+
+// LoadKeyring reads the signing keys from the environment. It is called at
+// startup so a bad key configuration stops the server instead of failing
+// every login.
+func LoadKeyring() error {
+	// Not a token key once retired, but SignValue still depends on it
+	if config.GetSecretKey() == "" {
+		return errors.New("SECRET_KEY must be set")
+	}
+	cfg, err := config.GetJWTKeyConfig()
+	if err != nil {
+		return err
+	}
+	ring, err := NewKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	keyringMu.Lock()
+	activeKeyring = ring
+	keyringMu.Unlock()
+	return nil
+}
+
+// CurrentKeyring returns the loaded keyring, loading it on first use.
+func CurrentKeyring() (*Keyring, error) {
+	keyringMu.Lock()
+	ring := activeKeyring
+	keyringMu.Unlock()
+	if ring != nil {
+		return ring, nil
+	}
+	if err := LoadKeyring(); err != nil {
+		return nil, err
+	}
+	return CurrentKeyring()
+}
+
+// NewKeyring builds a keyring from its configuration.
+func NewKeyring(cfg config.JWTKeyConfig) (*Keyring, error) {
+	retired := make(map[string]bool, len(cfg.Retired))
+	for _, id := range cfg.Retired {
+		retired[id] = true
+	}
+
+	ring := &Keyring{keys: make(map[string]*SigningKey, len(cfg.Keys))}
+	for _, spec := range cfg.Keys {
+		if spec.ID == "" {
+			return nil, errors.New("jwt keys: key without an ID")
+		}
+		if _, ok := ring.keys[spec.ID]; ok {
+			return nil, fmt.Errorf("jwt keys: duplicate key ID %q", spec.ID)
+		}
+		key, err := loadSigningKey(spec)
+		if err != nil {
+			return nil, fmt.Errorf("jwt keys: key %q: %w", spec.ID, err)
+		}
+		key.Retired = retired[spec.ID]
+		ring.keys[spec.ID] = key
+	}
+
+	active, ok := ring.keys[cfg.ActiveID]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("jwt keys: active key %q is not configured", cfg.ActiveID)
+	case active.Retired:
+		return nil, fmt.Errorf("jwt keys: active key %q is retired", cfg.ActiveID)
+	case active.signKey == nil:
+		return nil, fmt.Errorf("jwt keys: active key %q has no private key", cfg.ActiveID)
+	}
+	ring.active = active
+	return ring, nil
+}
+
+func loadSigningKey(spec config.JWTKeySpec) (*SigningKey, error) {
+	key := &SigningKey{ID: spec.ID}
+	switch spec.Algorithm {
+	case "HS256":
+		if spec.Material == "" {
+			return nil, errors.New("empty secret")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey = []byte(spec.Material)
+		key.verifyKey = key.signKey
+		return key, nil
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", spec.Algorithm)
+	}
+
+	data, err := os.ReadFile(spec.Material)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.signKey, key.verifyKey = k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.verifyKey = k
+	case ed25519.PrivateKey:
+		key.signKey, key.verifyKey = k, k.Public()
+	case ed25519.PublicKey:
+		key.verifyKey = k
+	}
+	switch k := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		if key.Method != jwt.SigningMethodRS256 {
+			return nil, errors.New("RSA key used with " + spec.Algorithm)
+		}
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+	case ed25519.PublicKey:
+		if key.Method != jwt.SigningMethodEdDSA {
+			return nil, errors.New("Ed25519 key used with " + spec.Algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// Sign signs claims with the active key.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.Method, claims)
+	token.Header["kid"] = k.active.ID
+	return token.SignedString(k.active.signKey)
+}
+
+// Parse verifies a token against the key named by its kid header. Tokens
+// signed with a retired or unknown key are rejected.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) error {
+	methods := make([]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		methods = append(methods, key.Method.Alg())
+	}
+	options = append(options, jwt.WithValidMethods(methods))
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = config.LegacyJWTKeyID
+		}
+		key, ok := k.keys[kid]
+		if !ok || key.Retired {
+			return nil, fmt.Errorf("unknown or retired signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("signing key %q does not use %s", kid, token.Method.Alg())
+		}
+		return key.verifyKey, nil
+	}, options...)
+	return err
+}
+
+// PublicKeys returns the asymmetric keys that are still accepted, for
+// services that verify tokens themselves. HS256 secrets are never published.
+func (k *Keyring) PublicKeys() []JWK {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	keys := make([]JWK, 0, len(ids))
+	for _, id := range ids {
+		key := k.keys[id]
+		if key.Retired {
+			continue
+		}
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Passwords that pass the length and character rules but are still guessed
+// first.
+var commonPasswords = map[string]bool{
+	"password1": true, "password123": true, "passw0rd": true, "qwerty123": true,
+	"iloveyou1": true, "welcome1": true, "welcome123": true, "admin123": true,
+	"abc12345": true, "abcd1234": true, "letmein1": true, "gradspace1": true,
+	"gradspace123": true, "1q2w3e4r": true, "qwertyuiop1": true,
+}
+
+// CheckPasswordStrength returns why a password is too weak, or "" when it is
+// acceptable. Personal values such as the user's name or the local part of
+// their email must not appear in it.
+func CheckPasswordStrength(password string, personal ...string) string {
+	if len(password) < 8 {
+		return "Password must be at least 8 characters"
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		return "Password must be at most 72 characters"
+	}
+
+	var hasLetter, hasOther bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		} else {
+			hasOther = true
+		}
+	}
+	if !hasLetter || !hasOther {
+		return "Password must contain letters and at least one number or symbol"
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return "This password is too common"
+	}
+	for _, value := range personal {
+		for _, part := range strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+			return r == '@' || r == '.' || r == ' ' || r == '_' || r == '-'
+		}) {
+			if len(part) >= 4 && strings.Contains(lower, part) {
+				return "Password must not contain your name, username or email"
+			}
+		}
+	}
+	return ""
+}
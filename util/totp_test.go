@@ -0,0 +1,94 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA-1 seed from RFC 6238 appendix B, "12345678901234567890", base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Appendix B lists 8-digit codes; these are their last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, tc := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode at %d: %v", tc.unix, err)
+		}
+		if code != tc.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", tc.unix, code, tc.code)
+		}
+	}
+}
+
+func TestTOTPCodeLowercaseSecret(t *testing.T) {
+	code, err := TOTPCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", TOTPStep(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Errorf("TOTPCode with lowercase secret = %q, %v", code, err)
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", "050471", 0, step, true},
+		{"surrounding whitespace", " 050471 ", 0, step, true},
+		{"previous step", "081804", 0, step - 1, true},
+		{"wrong code", "123456", 0, 0, false},
+		{"too short", "50471", 0, 0, false},
+		{"too long", "0504710", 0, 0, false},
+		{"replay of last step", "050471", step, 0, false},
+		{"earlier than last step", "081804", step, 0, false},
+		{"step after last step", "050471", step - 1, step, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotStep, ok := VerifyTOTP(rfc6238Secret, tc.code, now, tc.lastStep)
+			if ok != tc.wantOK || gotStep != tc.wantStep {
+				t.Errorf("VerifyTOTP(%q, lastStep %d) = %d, %v; want %d, %v",
+					tc.code, tc.lastStep, gotStep, ok, tc.wantStep, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, err := TOTPCode(rfc6238Secret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ok := VerifyTOTP(rfc6238Secret, code, now, 0)
+		if want := offset >= -totpSkew && offset <= totpSkew; ok != want {
+			t.Errorf("code from step offset %d accepted = %v, want %v", offset, ok, want)
+		}
+	}
+}
@@ -2,14 +2,20 @@ package util
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
-	"fmt"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"html/template"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+
+	"gradspaceBK/config"
 )
 
 func HashPassword(password string) (string, error) {
@@ -21,25 +27,56 @@ func ComparePassword(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func GenerateToken(userId string) (map[string]string, error) {
-	key := []byte(os.Getenv("SECRET_KEY"))
-	access_jwt_instance := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"user_id": userId,
-			"type":    "access",
-			"exp":     time.Now().Add(time.Hour * 24).Unix(),
-		})
-	access_token, err := access_jwt_instance.SignedString(key)
+// SessionTTL is how long a login lasts; it is the lifetime of the refresh token.
+const SessionTTL = time.Hour * 24 * 7
+
+// Token claim values. Tokens are only accepted by the API they were issued
+// for, and access and refresh tokens are told apart by their type.
+const (
+	TokenIssuer      = "gradspace"
+	TokenAudience    = "gradspace-api"
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// Claims are the claims carried by the access and refresh tokens.
+type Claims struct {
+	UserID    string `json:"user_id"`
+	Role      string `json:"role,omitempty"`
+	Type      string `json:"type"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// GenerateToken issues the access and refresh tokens for a session. The role
+// is informational only: authorization checks read it from the database.
+func GenerateToken(userId, role, sessionId string) (map[string]string, error) {
+	ring, err := CurrentKeyring()
 	if err != nil {
 		return nil, err
 	}
-	refresh_jwt_instance := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"user_id": userId,
-			"type":    "refresh",
-			"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
-		})
-	refresh_token, err := refresh_jwt_instance.SignedString(key)
+	now := time.Now()
+	newClaims := func(tokenType string, ttl time.Duration) Claims {
+		return Claims{
+			UserID:    userId,
+			Role:      role,
+			Type:      tokenType,
+			SessionID: sessionId,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    TokenIssuer,
+				Subject:   userId,
+				Audience:  jwt.ClaimStrings{TokenAudience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			},
+		}
+	}
+
+	access_token, err := ring.Sign(newClaims(TokenTypeAccess, time.Hour*24))
+	if err != nil {
+		return nil, err
+	}
+	refresh_token, err := ring.Sign(newClaims(TokenTypeRefresh, SessionTTL))
 	if err != nil {
 		return nil, err
 	}
@@ -49,24 +86,58 @@ func GenerateToken(userId string) (map[string]string, error) {
 	}, nil
 }
 
-func VerifyToken(tokenString string) (jwt.MapClaims, error) {
-	key := []byte(os.Getenv("SECRET_KEY"))
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return key, nil
-	})
-
+// VerifyToken checks a token's signature, issuer, audience and expiry and
+// returns its claims. Callers must check the token type themselves.
+func VerifyToken(tokenString string) (*Claims, error) {
+	ring, err := CurrentKeyring()
 	if err != nil {
 		return nil, err
 	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
-	} else {
+	claims := &Claims{}
+	if err := ring.Parse(tokenString, claims,
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(TokenAudience),
+		jwt.WithExpirationRequired(),
+	); err != nil {
 		return nil, err
 	}
+	if claims.UserID == "" || claims.SessionID == "" {
+		return nil, errors.New("token is missing the user or session")
+	}
+	return claims, nil
+}
+
+// SignValue returns value with an HMAC signature appended. The purpose is
+// mixed into the signature so a token issued for one feature is not accepted
+// by another.
+func SignValue(purpose, value string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value))
+	return payload + "." + signPayload(purpose, payload)
+}
+
+// VerifySignedValue checks a token produced by SignValue and returns the value.
+func VerifySignedValue(purpose, token string) (string, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return "", errors.New("malformed token")
+	}
+	if config.GetSecretKey() == "" {
+		return "", errors.New("SECRET_KEY is not set")
+	}
+	if !hmac.Equal([]byte(signature), []byte(signPayload(purpose, payload))) {
+		return "", errors.New("invalid token signature")
+	}
+	value, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.New("malformed token")
+	}
+	return string(value), nil
+}
+
+func signPayload(purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.GetSecretKey()))
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
 
 func GenerateOtp() (string, error) {
@@ -93,4 +164,11 @@ func RenderTemplate(templatePath string, data interface{}) (string, error) {
 	}
 
 	return rendered.String(), nil
-}
\ No newline at end of file
+}
+
+// HashToken returns the stored form of a random secret token such as a
+// password reset token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}